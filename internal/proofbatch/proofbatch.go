@@ -0,0 +1,103 @@
+// Package proofbatch holds the chunked-fetch and archive-node fallback
+// plumbing shared by the mapbased and minime token packages: splitting a
+// large key set into eth_getProof-sized chunks, and retrying a proof
+// lookup across fallback RPC endpoints when the current one turns out not
+// to be an archive node for the requested block.
+package proofbatch
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/storage-proofs-eth-go/ethstorageproof"
+)
+
+// NumProofChunks returns how many chunkSize-sized chunks numKeys splits
+// into, rounding up so a partial final chunk still gets its own entry.
+func NumProofChunks(numKeys, chunkSize int) int {
+	return (numKeys + chunkSize - 1) / chunkSize
+}
+
+// ProofChunkBounds returns the [start, end) slice bounds of chunk i among
+// numKeys keys split into chunkSize-sized chunks, clamping the final chunk's
+// end to numKeys.
+func ProofChunkBounds(i, numKeys, chunkSize int) (start, end int) {
+	start = i * chunkSize
+	end = start + chunkSize
+	if end > numKeys {
+		end = numKeys
+	}
+	return start, end
+}
+
+// EndpointAttempt records the outcome of trying a single RPC endpoint from
+// BalanceAt/ProofAt.
+type EndpointAttempt struct {
+	Endpoint string
+	Err      error
+}
+
+// EndpointsExhaustedError is returned by ProofAt/BalanceAt when neither the
+// primary endpoint nor any configured fallback endpoint could serve a proof
+// for the requested block, together with the reason each one failed.
+type EndpointsExhaustedError struct {
+	Tried []EndpointAttempt
+}
+
+func (e *EndpointsExhaustedError) Error() string {
+	var b strings.Builder
+	b.WriteString("no RPC endpoint could serve a proof for this block:")
+	for _, t := range e.Tried {
+		fmt.Fprintf(&b, "\n  %s: %v", t.Endpoint, t.Err)
+	}
+	return b.String()
+}
+
+// IsArchiveNodeErr reports whether err looks like the "missing trie node"
+// error a pruned/non-archive node returns when asked for historical state it
+// no longer keeps.
+func IsArchiveNodeErr(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "missing trie node")
+}
+
+// Prover is implemented by token types (Mapbased, Minime) that can fetch a
+// single holder's storage proof and be (re-)initialized against a
+// different RPC endpoint.
+type Prover interface {
+	Init(tokenAddress, web3endpoint string) error
+	GetProof(holder common.Address, block *big.Int, slot int) (*ethstorageproof.StorageProof, error)
+}
+
+// ProofAt probes endpoints in order, starting with m (reusing its existing
+// connection) and falling back, for each subsequent endpoint, to a fresh
+// Prover built by newInstance and initialized against it, whenever the
+// current one turns out not to be an archive node for the requested block.
+// It returns the proof together with whichever Prover actually served it,
+// so callers like BalanceAt can pull token metadata from the endpoint that
+// worked rather than redialing the original one. Returns an
+// *EndpointsExhaustedError if none of the endpoints can serve the proof.
+func ProofAt(m Prover, newInstance func() Prover, tokenAddress string, endpoints []string,
+	holder common.Address, block *big.Int, slot int) (*ethstorageproof.StorageProof, Prover, error) {
+	var tried []EndpointAttempt
+	for i, endpoint := range endpoints {
+		mm := m
+		if i > 0 {
+			mm = newInstance()
+			if err := mm.Init(tokenAddress, endpoint); err != nil {
+				tried = append(tried, EndpointAttempt{endpoint, err})
+				continue
+			}
+		}
+		sproof, err := mm.GetProof(holder, block, slot)
+		if err == nil {
+			return sproof, mm, nil
+		}
+		tried = append(tried, EndpointAttempt{endpoint, err})
+		if !IsArchiveNodeErr(err) {
+			return nil, nil, err
+		}
+	}
+	return nil, nil, &EndpointsExhaustedError{Tried: tried}
+}
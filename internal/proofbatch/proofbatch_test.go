@@ -0,0 +1,71 @@
+package proofbatch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestIsArchiveNodeErr(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("missing trie node abcd123 (path )"), true},
+		{errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := IsArchiveNodeErr(tt.err); got != tt.want {
+			t.Errorf("IsArchiveNodeErr(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestNumProofChunks(t *testing.T) {
+	tests := []struct {
+		numKeys, chunkSize, want int
+	}{
+		{0, 1000, 0},
+		{1, 1000, 1},
+		{1000, 1000, 1},
+		{1001, 1000, 2},
+		{2500, 1000, 3},
+	}
+	for _, tt := range tests {
+		if got := NumProofChunks(tt.numKeys, tt.chunkSize); got != tt.want {
+			t.Errorf("NumProofChunks(%d, %d) = %d, want %d", tt.numKeys, tt.chunkSize, got, tt.want)
+		}
+	}
+}
+
+func TestProofChunkBounds(t *testing.T) {
+	tests := []struct {
+		i, numKeys, chunkSize int
+		wantStart, wantEnd    int
+	}{
+		{0, 2500, 1000, 0, 1000},
+		{1, 2500, 1000, 1000, 2000},
+		{2, 2500, 1000, 2000, 2500},
+	}
+	for _, tt := range tests {
+		start, end := ProofChunkBounds(tt.i, tt.numKeys, tt.chunkSize)
+		if start != tt.wantStart || end != tt.wantEnd {
+			t.Errorf("ProofChunkBounds(%d, %d, %d) = (%d, %d), want (%d, %d)",
+				tt.i, tt.numKeys, tt.chunkSize, start, end, tt.wantStart, tt.wantEnd)
+		}
+	}
+}
+
+func TestEndpointsExhaustedErrorMessage(t *testing.T) {
+	err := &EndpointsExhaustedError{Tried: []EndpointAttempt{
+		{Endpoint: "https://primary", Err: errors.New("missing trie node")},
+		{Endpoint: "https://fallback", Err: errors.New("dial tcp: timeout")},
+	}}
+	msg := err.Error()
+	for _, want := range []string{"https://primary", "missing trie node", "https://fallback", "dial tcp: timeout"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("EndpointsExhaustedError.Error() = %q, want it to contain %q", msg, want)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// Package l2 produces storage proofs for tokens living on an OP-stack L2
+// (Optimism, Base and similar rollups) in a form that can be verified
+// against an L1-anchored state root, so a caller does not have to trust
+// the L2 RPC it fetched the proof from. It fetches the storage proof from
+// the L2 node, then couples it with the L1 inclusion proof of the
+// L2OutputOracle entry that commits to the L2 block the storage proof was
+// taken at, and with the L2 block data needed to tie that storage proof
+// to the committed output root.
+package l2
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/vocdoni/storage-proofs-eth-go/ethstorageproof"
+	"github.com/vocdoni/storage-proofs-eth-go/helpers"
+	"github.com/vocdoni/storage-proofs-eth-go/lightproof"
+)
+
+// l2ToL1MessagePasser is the address of the L2ToL1MessagePasser predeploy
+// that every OP-stack chain carries at the same address. Its storage root
+// at a given L2 block is the "withdrawal storage root" mixed into that
+// block's output root.
+var l2ToL1MessagePasser = common.HexToAddress("0x4200000000000000000000000000000000000016")
+
+// outputRootVersion is the output-root version byte string used by the
+// standard (bedrock) OP-stack output root commitment scheme.
+var outputRootVersion = common.Hash{}
+
+// Bundle couples an L2 storage proof with the L1 inclusion proof of the
+// L2 output root it was taken against, plus the L2 block data needed to
+// recompute that output root and confirm the storage proof was actually
+// taken against it.
+type Bundle struct {
+	L2StorageProof *ethstorageproof.StorageProof
+	L2BlockNumber  *big.Int
+	L2StateRoot    common.Hash
+	L2BlockHash    common.Hash
+	// L2MessagePasserRoot is the storage root of the L2ToL1MessagePasser
+	// predeploy at L2BlockNumber, the "withdrawal storage root" component
+	// of the output root commitment.
+	L2MessagePasserRoot common.Hash
+	OutputIndex         *big.Int
+	OutputRoot          common.Hash
+	L1OutputProof       *ethstorageproof.StorageProof
+}
+
+// Token fetches storage proofs from an OP-stack L2 RPC for a given token
+// contract.
+type Token struct {
+	l2cli *ethclient.Client
+	l2rpc *rpc.Client
+}
+
+// Init dials the L2 RPC endpoint.
+func (t *Token) Init(l2endpoint string) error {
+	cli, err := rpc.Dial(l2endpoint)
+	if err != nil {
+		return fmt.Errorf("rpc.Dial: %w", err)
+	}
+	t.l2rpc = cli
+	t.l2cli = ethclient.NewClient(cli)
+	return nil
+}
+
+// GetProof fetches a storage proof for holder's balance on the L2 token
+// contract at l2Block, then bundles it with the L1 proof that oracle has
+// published an output root covering l2Block at outputIndex, and with the
+// L2 block data (state root, message passer storage root, block hash)
+// needed to recompute that output root independently of the L2 RPC's say-so.
+func (t *Token) GetProof(ctx context.Context, tokenAddr, holder common.Address, slot int,
+	l2Block *big.Int, oracle *L1OutputOracle, outputIndex *big.Int) (*Bundle, error) {
+	key, err := helpers.GetMapSlot(holder.Hex(), slot)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve the target block to a concrete number up front, so every
+	// subsequent call below (the token's storage proof, the message
+	// passer's storage proof) is pinned to the exact same block rather than
+	// each independently re-resolving "latest" and risking a mismatch if
+	// the L2 advances between calls.
+	header, err := t.l2cli.HeaderByNumber(ctx, l2Block)
+	if err != nil {
+		return nil, fmt.Errorf("HeaderByNumber: %w", err)
+	}
+	blockTag := fmt.Sprintf("0x%x", header.Number)
+
+	var l2proof ethstorageproof.StorageProof
+	if err := t.l2rpc.CallContext(ctx, &l2proof, "eth_getProof", tokenAddr,
+		[]string{fmt.Sprintf("%x", key)}, blockTag); err != nil {
+		return nil, fmt.Errorf("l2 eth_getProof: %w", err)
+	}
+
+	var messagePasserProof ethstorageproof.StorageProof
+	if err := t.l2rpc.CallContext(ctx, &messagePasserProof, "eth_getProof", l2ToL1MessagePasser,
+		[]string{}, blockTag); err != nil {
+		return nil, fmt.Errorf("l2 eth_getProof (message passer): %w", err)
+	}
+
+	outputRoot, err := oracle.GetL2Output(ctx, outputIndex)
+	if err != nil {
+		return nil, fmt.Errorf("GetL2Output: %w", err)
+	}
+	l1proof, err := oracle.GetOutputProof(ctx, outputIndex)
+	if err != nil {
+		return nil, fmt.Errorf("GetOutputProof: %w", err)
+	}
+
+	return &Bundle{
+		L2StorageProof:      &l2proof,
+		L2BlockNumber:       header.Number,
+		L2StateRoot:         header.Root,
+		L2BlockHash:         header.Hash(),
+		L2MessagePasserRoot: messagePasserProof.StorageHash,
+		OutputIndex:         outputIndex,
+		OutputRoot:          outputRoot,
+		L1OutputProof:       l1proof,
+	}, nil
+}
+
+// VerifyL2Proof verifies bundle against an L1 storage root the caller
+// already trusts (for instance, one taken from an L1 block header it
+// independently verified), without ever trusting the L2 RPC that produced
+// the L2 storage proof. It establishes the full chain of trust from that L1
+// root down to the L2 storage value:
+//  1. bundle.L2StorageProof's account proof verifies against the claimed
+//     L2StateRoot (not merely against itself, which any party controlling
+//     the L2 RPC could fabricate independently of the real L2 state).
+//  2. L2StateRoot, L2MessagePasserRoot and L2BlockHash recompute the exact
+//     OutputRoot the bundle claims, tying the storage proof to that root.
+//  3. The L1 proof of that OutputRoot is itself anchored in l1StorageRoot.
+func VerifyL2Proof(bundle *Bundle, l1StorageRoot common.Hash) (bool, error) {
+	if ok, err := ethstorageproof.VerifyEIP1186(bundle.L2StorageProof); !ok {
+		return false, fmt.Errorf("L2 storage proof invalid: %w", err)
+	}
+
+	accountProof, err := decodeHexNodes(bundle.L2StorageProof.AccountProof)
+	if err != nil {
+		return false, fmt.Errorf("decoding L2 account proof: %w", err)
+	}
+	storageRoot, err := lightproof.VerifyAccountProof(
+		bundle.L2StateRoot.Bytes(), bundle.L2StorageProof.Address.Bytes(), accountProof)
+	if err != nil {
+		return false, fmt.Errorf("L2 account proof does not verify against the claimed L2 state root: %w", err)
+	}
+	if !bytes.Equal(storageRoot, bundle.L2StorageProof.StorageHash.Bytes()) {
+		return false, fmt.Errorf("L2 account's storage root does not match the storage proof's StorageHash")
+	}
+
+	if computeOutputRoot(bundle.L2StateRoot, bundle.L2MessagePasserRoot, bundle.L2BlockHash) != bundle.OutputRoot {
+		return false, fmt.Errorf("recomputed output root does not match the bundle's claimed output root")
+	}
+
+	if bundle.L1OutputProof.StorageHash != l1StorageRoot {
+		return false, fmt.Errorf("L1 output proof does not match the trusted L1 storage root")
+	}
+	if ok, err := ethstorageproof.VerifyEIP1186(bundle.L1OutputProof); !ok {
+		return false, fmt.Errorf("L1 output inclusion proof invalid: %w", err)
+	}
+	got, err := decodeOutputRoot(bundle.L1OutputProof)
+	if err != nil {
+		return false, fmt.Errorf("decodeOutputRoot: %w", err)
+	}
+	if got != bundle.OutputRoot {
+		return false, fmt.Errorf("claimed output root does not match the L1-proven value")
+	}
+	return true, nil
+}
+
+// computeOutputRoot recomputes the standard (bedrock) OP-stack output root
+// commitment: keccak256(version ++ stateRoot ++ messagePasserStorageRoot ++
+// blockHash).
+func computeOutputRoot(stateRoot, messagePasserRoot, blockHash common.Hash) common.Hash {
+	buf := make([]byte, 0, 4*common.HashLength)
+	buf = append(buf, outputRootVersion.Bytes()...)
+	buf = append(buf, stateRoot.Bytes()...)
+	buf = append(buf, messagePasserRoot.Bytes()...)
+	buf = append(buf, blockHash.Bytes()...)
+	return common.BytesToHash(crypto.Keccak256(buf))
+}
+
+// decodeHexNodes decodes the hex-encoded RLP trie nodes returned by
+// eth_getProof (e.g. AccountProof) into raw bytes.
+func decodeHexNodes(nodes []string) ([][]byte, error) {
+	out := make([][]byte, len(nodes))
+	for i, n := range nodes {
+		b, err := hexutil.Decode(n)
+		if err != nil {
+			return nil, fmt.Errorf("node %d: %w", i, err)
+		}
+		out[i] = b
+	}
+	return out, nil
+}
+
+// toBlockTag renders block as the JSON-RPC block parameter, defaulting to
+// "latest" when nil.
+func toBlockTag(block *big.Int) string {
+	if block == nil {
+		return "latest"
+	}
+	return fmt.Sprintf("0x%x", block)
+}
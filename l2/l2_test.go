@@ -0,0 +1,51 @@
+package l2
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestComputeOutputRoot(t *testing.T) {
+	stateRoot := common.HexToHash("0x" + strings.Repeat("11", 32))
+	messagePasserRoot := common.HexToHash("0x" + strings.Repeat("22", 32))
+	blockHash := common.HexToHash("0x" + strings.Repeat("33", 32))
+
+	want := crypto.Keccak256Hash(
+		outputRootVersion.Bytes(), stateRoot.Bytes(), messagePasserRoot.Bytes(), blockHash.Bytes())
+
+	if got := computeOutputRoot(stateRoot, messagePasserRoot, blockHash); got != want {
+		t.Errorf("computeOutputRoot() = %x, want %x", got, want)
+	}
+}
+
+func TestComputeOutputRootChangesWithInputs(t *testing.T) {
+	a := computeOutputRoot(common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03"))
+	b := computeOutputRoot(common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x04"))
+	if a == b {
+		t.Error("changing the block hash input should change the output root")
+	}
+}
+
+func TestDecodeHexNodes(t *testing.T) {
+	nodes := []string{"0xdeadbeef", "0xcafe"}
+	got, err := decodeHexNodes(nodes)
+	if err != nil {
+		t.Fatalf("decodeHexNodes: %v", err)
+	}
+	want := [][]byte{{0xde, 0xad, 0xbe, 0xef}, {0xca, 0xfe}}
+	for i := range want {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("node %d = %x, want %x", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDecodeHexNodesInvalid(t *testing.T) {
+	if _, err := decodeHexNodes([]string{"not-hex"}); err == nil {
+		t.Fatal("expected an error decoding a non-hex node")
+	}
+}
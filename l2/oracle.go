@@ -0,0 +1,85 @@
+package l2
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/vocdoni/storage-proofs-eth-go/ethstorageproof"
+)
+
+// l2OutputsSlot is the storage slot of the `l2Outputs` dynamic array in the
+// standard OP-stack L2OutputOracle contract. Each element occupies two
+// storage words: the first holds the outputRoot, the second packs
+// timestamp and l2BlockNumber.
+const l2OutputsSlot = 3
+
+// getL2OutputSelector is the 4-byte selector for getL2Output(uint256).
+var getL2OutputSelector = crypto.Keccak256([]byte("getL2Output(uint256)"))[:4]
+
+// L1OutputOracle is a thin binding over an OP-stack L2OutputOracle contract
+// deployed on L1, used to fetch and prove the output root a given index
+// commits to.
+type L1OutputOracle struct {
+	l1cli   *ethclient.Client
+	address common.Address
+}
+
+// NewL1OutputOracle builds a binding for the L2OutputOracle deployed at
+// address, reachable through l1cli.
+func NewL1OutputOracle(l1cli *ethclient.Client, address common.Address) *L1OutputOracle {
+	return &L1OutputOracle{l1cli: l1cli, address: address}
+}
+
+// GetL2Output calls getL2Output(outputIndex) on the oracle contract and
+// returns the proposed output root.
+func (o *L1OutputOracle) GetL2Output(ctx context.Context, outputIndex *big.Int) (common.Hash, error) {
+	data := append(append([]byte{}, getL2OutputSelector...), common.LeftPadBytes(outputIndex.Bytes(), 32)...)
+	out, err := o.l1cli.CallContract(ctx, ethereum.CallMsg{To: &o.address, Data: data}, nil)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("eth_call getL2Output: %w", err)
+	}
+	if len(out) < 32 {
+		return common.Hash{}, fmt.Errorf("unexpected getL2Output return length %d", len(out))
+	}
+	return common.BytesToHash(out[:32]), nil
+}
+
+// outputElementSlot returns the storage slot holding the outputRoot word of
+// l2Outputs[outputIndex].
+func outputElementSlot(outputIndex *big.Int) common.Hash {
+	base := crypto.Keccak256(common.LeftPadBytes(big.NewInt(l2OutputsSlot).Bytes(), 32))
+	elementsPerEntry := big.NewInt(2)
+	offset := new(big.Int).Mul(outputIndex, elementsPerEntry)
+	slot := new(big.Int).Add(new(big.Int).SetBytes(base), offset)
+	return common.BigToHash(slot)
+}
+
+// GetOutputProof returns the storage merkle proof for the outputRoot word
+// of l2Outputs[outputIndex], against the oracle contract's L1 account.
+func (o *L1OutputOracle) GetOutputProof(ctx context.Context, outputIndex *big.Int) (*ethstorageproof.StorageProof, error) {
+	block, err := o.l1cli.BlockByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("BlockByNumber: %w", err)
+	}
+	slot := outputElementSlot(outputIndex)
+	var proof ethstorageproof.StorageProof
+	if err := o.l1cli.Client().CallContext(ctx, &proof, "eth_getProof", o.address,
+		[]string{fmt.Sprintf("%x", slot)}, toBlockTag(block.Number())); err != nil {
+		return nil, fmt.Errorf("eth_getProof: %w", err)
+	}
+	return &proof, nil
+}
+
+// decodeOutputRoot extracts the outputRoot word proven by an L1OutputOracle
+// storage proof obtained from GetOutputProof.
+func decodeOutputRoot(proof *ethstorageproof.StorageProof) (common.Hash, error) {
+	if len(proof.StorageProof) == 0 || proof.StorageProof[0].Value == nil {
+		return common.Hash{}, fmt.Errorf("empty storage proof")
+	}
+	return common.BigToHash(proof.StorageProof[0].Value), nil
+}
@@ -0,0 +1,56 @@
+package lightproof
+
+import "testing"
+
+// FuzzVerifyStorageProof feeds malformed/forged RLP trie nodes through the
+// verifier to make sure it always returns an error instead of panicking,
+// regardless of how the bytes are mangled.
+func FuzzVerifyStorageProof(f *testing.F) {
+	key := []byte("storage-slot")
+	value := []byte{0x2a}
+	root, proof := singleLeafTrie(key, value)
+
+	f.Add(proof[0])
+	f.Add([]byte{})
+	f.Add([]byte{0xc0})
+	f.Add(append(append([]byte{}, proof[0]...), 0xff))
+
+	f.Fuzz(func(t *testing.T, node []byte) {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Fatalf("VerifyStorageProof panicked on input %x: %v", node, r)
+			}
+		}()
+		_, _ = VerifyStorageProof(root, key, [][]byte{node})
+	})
+}
+
+// FuzzMarshalUnmarshal checks that Unmarshal never panics on arbitrary
+// (possibly truncated or corrupted) CBOR input.
+func FuzzMarshalUnmarshal(f *testing.F) {
+	p := &Proof{
+		StateRoot:    []byte{1, 2, 3},
+		Address:      []byte{4, 5, 6, 7},
+		AccountProof: [][]byte{{0xaa}, {0xbb, 0xcc}},
+		StorageHash:  []byte{8, 9},
+		Key:          []byte("key"),
+		Value:        []byte{0x2a},
+		StorageProof: [][]byte{{0xdd, 0xee, 0xff}},
+	}
+	data, err := Marshal(p)
+	if err != nil {
+		f.Fatalf("Marshal: %v", err)
+	}
+	f.Add(data)
+	f.Add([]byte{})
+	f.Add(data[:len(data)/2])
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Fatalf("Unmarshal panicked on input %x: %v", data, r)
+			}
+		}()
+		_, _ = Unmarshal(data)
+	})
+}
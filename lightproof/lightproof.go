@@ -0,0 +1,122 @@
+// Package lightproof verifies Ethereum EIP-1186 account and storage Merkle
+// proofs from pre-fetched data alone: a state root, an account proof, a
+// storage hash, a storage proof, a key and an expected value. It has no
+// dependency on go-ethereum's account or RPC types (or on go-ethereum at
+// all), so it can be embedded in light clients, mobile SDKs (gomobile
+// bindings) and in-browser wasm verifiers without pulling in a full node's
+// transitive dependency graph.
+package lightproof
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// keccak256 hashes data using the pure-Go Keccak-256 implementation, so
+// this package never needs go-ethereum/crypto (and the cgo/secp256k1
+// baggage that pulls in).
+func keccak256(data []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// Proof bundles everything needed to verify that an account held a given
+// storage value at a block, using only data the caller already fetched
+// (typically via eth_getProof) and a state root it already trusts.
+type Proof struct {
+	StateRoot    []byte
+	Address      []byte
+	AccountProof [][]byte
+	StorageHash  []byte
+	Key          []byte
+	Value        []byte
+	StorageProof [][]byte
+}
+
+// account is the RLP representation of an account leaf in the state trie:
+// [nonce, balance, storageRoot, codeHash].
+type account struct {
+	Nonce       uint64
+	Balance     []byte
+	StorageRoot []byte
+	CodeHash    []byte
+}
+
+func decodeAccount(data []byte) (*account, error) {
+	items, err := rlpDecodeList(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) != 4 {
+		return nil, fmt.Errorf("unexpected account field count %d", len(items))
+	}
+	return &account{
+		Nonce:       new(big.Int).SetBytes(items[0]).Uint64(),
+		Balance:     items[1],
+		StorageRoot: items[2],
+		CodeHash:    items[3],
+	}, nil
+}
+
+// VerifyAccountProof checks accountProof against stateRoot and returns the
+// account's storage root if address is proven to exist.
+func VerifyAccountProof(stateRoot, address []byte, accountProof [][]byte) ([]byte, error) {
+	accountRLP, found, err := verifyProof(stateRoot, address, accountProof)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("account does not exist at this state root")
+	}
+	acc, err := decodeAccount(accountRLP)
+	if err != nil {
+		return nil, err
+	}
+	return acc.StorageRoot, nil
+}
+
+// VerifyStorageProof checks storageProof against storageHash and returns
+// the raw value (big-endian, with leading zero bytes stripped) stored at
+// key, or nil if the proof demonstrates key holds no value.
+func VerifyStorageProof(storageHash, key []byte, storageProof [][]byte) ([]byte, error) {
+	valueRLP, found, err := verifyProof(storageHash, key, storageProof)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	value, err := rlpDecodeString(valueRLP)
+	if err != nil {
+		return nil, fmt.Errorf("decoding stored value: %w", err)
+	}
+	return bytes.TrimLeft(value, "\x00"), nil
+}
+
+// Verify checks p end to end: that p.AccountProof proves p.Address has
+// storage root p.StorageHash under p.StateRoot, and that p.StorageProof
+// proves p.Key holds p.Value under p.StorageHash.
+func Verify(p *Proof) (bool, error) {
+	storageRoot, err := VerifyAccountProof(p.StateRoot, p.Address, p.AccountProof)
+	if err != nil {
+		return false, fmt.Errorf("account proof: %w", err)
+	}
+	if !bytes.Equal(storageRoot, p.StorageHash) {
+		return false, fmt.Errorf("storage root mismatch: account has %x, proof claims %x",
+			storageRoot, p.StorageHash)
+	}
+
+	value, err := VerifyStorageProof(p.StorageHash, p.Key, p.StorageProof)
+	if err != nil {
+		return false, fmt.Errorf("storage proof: %w", err)
+	}
+	expected := bytes.TrimLeft(p.Value, "\x00")
+	if !bytes.Equal(value, expected) {
+		return false, fmt.Errorf("value mismatch: proof holds %x, expected %x", value, expected)
+	}
+	return true, nil
+}
@@ -0,0 +1,289 @@
+package lightproof
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// rlpEncodeString and rlpEncodeList are minimal RLP encoders used only to
+// build synthetic trie nodes for these tests; production code only ever
+// decodes proofs fetched from a node, so lightproof.go has no need for an
+// encoder of its own.
+
+func rlpEncodeString(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return b
+	}
+	if len(b) < 56 {
+		return append([]byte{byte(0x80 + len(b))}, b...)
+	}
+	lenBytes := bigEndianTrimmed(len(b))
+	out := append([]byte{byte(0xb7 + len(lenBytes))}, lenBytes...)
+	return append(out, b...)
+}
+
+func rlpEncodeList(items ...[]byte) []byte {
+	var payload []byte
+	for _, it := range items {
+		payload = append(payload, it...)
+	}
+	if len(payload) < 56 {
+		return append([]byte{byte(0xc0 + len(payload))}, payload...)
+	}
+	lenBytes := bigEndianTrimmed(len(payload))
+	out := append([]byte{byte(0xf7 + len(lenBytes))}, lenBytes...)
+	return append(out, payload...)
+}
+
+func bigEndianTrimmed(n int) []byte {
+	b := []byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	for len(b) > 1 && b[0] == 0 {
+		b = b[1:]
+	}
+	return b
+}
+
+// singleLeafTrie builds the smallest possible trie: one leaf node at the
+// root holding value at the full nibble path of keccak256(key).
+func singleLeafTrie(key, value []byte) (rootHash []byte, proof [][]byte) {
+	path := bytesToNibbles(keccak256(key))
+	encodedPath := hexPrefixEncodeLeaf(path)
+	leaf := rlpEncodeList(rlpEncodeString(encodedPath), rlpEncodeString(rlpEncodeString(value)))
+	return keccak256(leaf), [][]byte{leaf}
+}
+
+// hexPrefixEncodeLeaf compact-encodes nibbles as a leaf path (terminator
+// flag set), mirroring the decoding done by hexPrefixDecode.
+func hexPrefixEncodeLeaf(nibbles []byte) []byte {
+	const terminator = byte(2)
+	odd := byte(len(nibbles) % 2)
+	prefixNibble := terminator + odd
+
+	var all []byte
+	if odd == 1 {
+		all = append([]byte{prefixNibble}, nibbles...)
+	} else {
+		all = append([]byte{prefixNibble, 0}, nibbles...)
+	}
+	out := make([]byte, len(all)/2)
+	for i := range out {
+		out[i] = all[2*i]<<4 | all[2*i+1]
+	}
+	return out
+}
+
+func TestVerifyStorageProofSingleLeaf(t *testing.T) {
+	key := []byte("storage-slot")
+	value := []byte{0x2a}
+	root, proof := singleLeafTrie(key, value)
+
+	got, err := VerifyStorageProof(root, key, proof)
+	if err != nil {
+		t.Fatalf("VerifyStorageProof: %v", err)
+	}
+	if !bytes.Equal(got, value) {
+		t.Fatalf("got value %x, expected %x", got, value)
+	}
+}
+
+func TestVerifyStorageProofWrongKey(t *testing.T) {
+	key := []byte("storage-slot")
+	value := []byte{0x2a}
+	root, proof := singleLeafTrie(key, value)
+
+	// The leaf's path diverges from the other key's path, so the proof
+	// demonstrates that key is absent rather than failing to verify.
+	got, err := VerifyStorageProof(root, []byte("a different key"), proof)
+	if err != nil {
+		t.Fatalf("VerifyStorageProof: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("expected no value for a key absent from the trie, got %x", got)
+	}
+}
+
+func TestVerifyStorageProofForgedNode(t *testing.T) {
+	key := []byte("storage-slot")
+	value := []byte{0x2a}
+	root, proof := singleLeafTrie(key, value)
+
+	forged := make([]byte, len(proof[0]))
+	copy(forged, proof[0])
+	forged[len(forged)-1] ^= 0xff
+
+	if _, err := VerifyStorageProof(root, key, [][]byte{forged}); err == nil {
+		t.Fatalf("expected a forged node to fail hash verification")
+	}
+}
+
+// twoLeafBranchTrie builds a trie with a real branch node at the root: two
+// keys whose keccak256 hashes diverge on their very first nibble, each
+// pointing (via hash reference, since the leaves are long enough to not be
+// embedded) at its own leaf.
+func twoLeafBranchTrie(keyA, valueA, keyB, valueB []byte) (rootHash []byte, proof [][]byte) {
+	pathA := bytesToNibbles(keccak256(keyA))
+	pathB := bytesToNibbles(keccak256(keyB))
+	if pathA[0] == pathB[0] {
+		panic("test keys must diverge on their first nibble")
+	}
+
+	leafA := rlpEncodeList(
+		rlpEncodeString(hexPrefixEncodeLeaf(pathA[1:])), rlpEncodeString(rlpEncodeString(valueA)))
+	leafB := rlpEncodeList(
+		rlpEncodeString(hexPrefixEncodeLeaf(pathB[1:])), rlpEncodeString(rlpEncodeString(valueB)))
+
+	items := make([][]byte, 17)
+	for i := range items {
+		items[i] = rlpEncodeString(nil)
+	}
+	items[pathA[0]] = rlpEncodeString(keccak256(leafA))
+	items[pathB[0]] = rlpEncodeString(keccak256(leafB))
+	branch := rlpEncodeList(items...)
+
+	return keccak256(branch), [][]byte{branch, leafA, leafB}
+}
+
+func TestVerifyStorageProofBranchNode(t *testing.T) {
+	keyA, valueA := []byte("storage-slot-a"), []byte{0x2a}
+	keyB, valueB := []byte("storage-slot-b"), []byte{0x7b}
+
+	// bytesToNibbles(keccak256(...)) of these two keys must diverge on the
+	// first nibble for twoLeafBranchTrie's branch to be well formed; brute
+	// force small suffixes until that holds.
+	for i := 0; ; i++ {
+		keyA = []byte(fmt.Sprintf("storage-slot-a-%d", i))
+		if bytesToNibbles(keccak256(keyA))[0] != bytesToNibbles(keccak256(keyB))[0] {
+			break
+		}
+	}
+
+	root, proof := twoLeafBranchTrie(keyA, valueA, keyB, valueB)
+
+	got, err := VerifyStorageProof(root, keyA, proof)
+	if err != nil {
+		t.Fatalf("VerifyStorageProof(keyA): %v", err)
+	}
+	if !bytes.Equal(got, valueA) {
+		t.Fatalf("got value %x for keyA, expected %x", got, valueA)
+	}
+
+	// Proof nodes are consumed in root-to-leaf order, so keyB's proof is the
+	// branch followed by its own leaf.
+	got, err = VerifyStorageProof(root, keyB, [][]byte{proof[0], proof[2]})
+	if err != nil {
+		t.Fatalf("VerifyStorageProof(keyB): %v", err)
+	}
+	if !bytes.Equal(got, valueB) {
+		t.Fatalf("got value %x for keyB, expected %x", got, valueB)
+	}
+}
+
+// hexPrefixEncodeExtension compact-encodes nibbles as an extension node's
+// path (terminator flag clear), mirroring hexPrefixEncodeLeaf.
+func hexPrefixEncodeExtension(nibbles []byte) []byte {
+	odd := byte(len(nibbles) % 2)
+	prefixNibble := odd
+
+	var all []byte
+	if odd == 1 {
+		all = append([]byte{prefixNibble}, nibbles...)
+	} else {
+		all = append([]byte{prefixNibble, 0}, nibbles...)
+	}
+	out := make([]byte, len(all)/2)
+	for i := range out {
+		out[i] = all[2*i]<<4 | all[2*i+1]
+	}
+	return out
+}
+
+// embeddedLeafTrie builds a trie holding a single key whose value is reached
+// through a root extension node consuming all but the last nibble of the
+// key's path, followed by a branch whose only populated slot holds the final
+// leaf. That leaf's RLP encoding is short enough (<32 bytes) to be embedded
+// directly in the branch rather than referenced by hash, as real
+// sparsely-populated storage tries often produce; the branch in turn embeds
+// in the extension for the same reason. The returned proof contains only the
+// root extension node: neither the branch nor the leaf gets its own proof
+// array entry, matching what a real eth_getProof response looks like for
+// such a trie.
+func embeddedLeafTrie(key, value []byte) (rootHash []byte, proof [][]byte) {
+	path := bytesToNibbles(keccak256(key))
+
+	leaf := rlpEncodeList(rlpEncodeString(hexPrefixEncodeLeaf(nil)), rlpEncodeString(rlpEncodeString(value)))
+	if len(leaf) >= 32 {
+		panic("embedded leaf fixture is too large to be embedded; shrink its value")
+	}
+
+	branchItems := make([][]byte, 17)
+	for i := range branchItems {
+		branchItems[i] = rlpEncodeString(nil)
+	}
+	// Inlined as a raw list item, not wrapped in an extra rlpEncodeString:
+	// per the MPT spec a short child is the node's own RLP encoding (here, a
+	// 2-item list) embedded as-is, not a byte string wrapping it.
+	branchItems[path[len(path)-1]] = leaf
+	branch := rlpEncodeList(branchItems...)
+	if len(branch) >= 32 {
+		panic("embedded branch fixture is too large to be embedded; add more empty slots")
+	}
+
+	extension := rlpEncodeList(rlpEncodeString(hexPrefixEncodeExtension(path[:len(path)-1])), branch)
+
+	return keccak256(extension), [][]byte{extension}
+}
+
+func TestVerifyStorageProofEmbeddedNode(t *testing.T) {
+	key := []byte("storage-slot")
+	value := []byte{0x2a}
+
+	root, proof := embeddedLeafTrie(key, value)
+
+	got, err := VerifyStorageProof(root, key, proof)
+	if err != nil {
+		t.Fatalf("VerifyStorageProof: %v", err)
+	}
+	if !bytes.Equal(got, value) {
+		t.Fatalf("got value %x, expected %x", got, value)
+	}
+}
+
+func TestVerifyStorageProofEmbeddedNodeWrongKey(t *testing.T) {
+	key := []byte("storage-slot")
+	value := []byte{0x2a}
+
+	root, proof := embeddedLeafTrie(key, value)
+
+	got, err := VerifyStorageProof(root, []byte("a different key"), proof)
+	if err != nil {
+		t.Fatalf("VerifyStorageProof: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("expected no value for a key absent from the trie, got %x", got)
+	}
+}
+
+func TestMarshalUnmarshalRoundTrip(t *testing.T) {
+	p := &Proof{
+		StateRoot:    []byte{1, 2, 3},
+		Address:      []byte{4, 5, 6, 7},
+		AccountProof: [][]byte{{0xaa}, {0xbb, 0xcc}},
+		StorageHash:  []byte{8, 9},
+		Key:          []byte("key"),
+		Value:        []byte{0x2a},
+		StorageProof: [][]byte{{0xdd, 0xee, 0xff}},
+	}
+	data, err := Marshal(p)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !bytes.Equal(got.StateRoot, p.StateRoot) || !bytes.Equal(got.Key, p.Key) ||
+		len(got.AccountProof) != len(p.AccountProof) || len(got.StorageProof) != len(p.StorageProof) {
+		t.Fatalf("round-tripped proof does not match original: %+v", got)
+	}
+}
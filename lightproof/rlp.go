@@ -0,0 +1,116 @@
+package lightproof
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// rlpDecodeItem decodes a single RLP item from the front of data and
+// returns whether it was a list, its raw payload, and the remaining bytes
+// after the item.
+func rlpDecodeItem(data []byte) (isList bool, payload, rest []byte, err error) {
+	if len(data) == 0 {
+		return false, nil, nil, fmt.Errorf("rlp: empty input")
+	}
+	b := data[0]
+	switch {
+	case b < 0x80:
+		return false, data[:1], data[1:], nil
+	case b < 0xb8:
+		n := int(b - 0x80)
+		if len(data) < 1+n {
+			return false, nil, nil, fmt.Errorf("rlp: short string out of bounds")
+		}
+		return false, data[1 : 1+n], data[1+n:], nil
+	case b < 0xc0:
+		ll := int(b - 0xb7)
+		if len(data) < 1+ll {
+			return false, nil, nil, fmt.Errorf("rlp: long string length out of bounds")
+		}
+		n := int(new(big.Int).SetBytes(data[1 : 1+ll]).Int64())
+		if n < 0 || n > len(data)-1-ll {
+			return false, nil, nil, fmt.Errorf("rlp: long string out of bounds")
+		}
+		return false, data[1+ll : 1+ll+n], data[1+ll+n:], nil
+	case b < 0xf8:
+		n := int(b - 0xc0)
+		if len(data) < 1+n {
+			return false, nil, nil, fmt.Errorf("rlp: short list out of bounds")
+		}
+		return true, data[1 : 1+n], data[1+n:], nil
+	default:
+		ll := int(b - 0xf7)
+		if len(data) < 1+ll {
+			return false, nil, nil, fmt.Errorf("rlp: long list length out of bounds")
+		}
+		n := int(new(big.Int).SetBytes(data[1 : 1+ll]).Int64())
+		if n < 0 || n > len(data)-1-ll {
+			return false, nil, nil, fmt.Errorf("rlp: long list out of bounds")
+		}
+		return true, data[1+ll : 1+ll+n], data[1+ll+n:], nil
+	}
+}
+
+// rlpDecodeList decodes data as an RLP list and returns the raw bytes of
+// each of its items. It rejects nested lists: this is used to decode
+// account leaves, whose four fields (nonce, balance, storageRoot,
+// codeHash) are always plain strings, never lists.
+func rlpDecodeList(data []byte) ([][]byte, error) {
+	return decodeListItems(data, false)
+}
+
+// rlpDecodeNodeItems decodes data as an RLP list representing a trie node
+// (branch or leaf/extension) and returns the raw bytes of each item. Unlike
+// rlpDecodeList, an item that is itself an RLP list is allowed and returned
+// as its full encoding (header included): per the MPT spec, a child
+// reference shorter than 32 bytes is embedded directly in its parent
+// rather than stored as a 32-byte hash, and an embedded branch/extension
+// node is itself list-encoded.
+func rlpDecodeNodeItems(data []byte) ([][]byte, error) {
+	return decodeListItems(data, true)
+}
+
+func decodeListItems(data []byte, allowEmbeddedNodes bool) ([][]byte, error) {
+	isList, payload, rest, err := rlpDecodeItem(data)
+	if err != nil {
+		return nil, err
+	}
+	if !isList {
+		return nil, fmt.Errorf("rlp: expected a list")
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("rlp: trailing bytes after list")
+	}
+	var items [][]byte
+	for len(payload) > 0 {
+		itemIsList, item, next, err := rlpDecodeItem(payload)
+		if err != nil {
+			return nil, err
+		}
+		if itemIsList {
+			if !allowEmbeddedNodes {
+				return nil, fmt.Errorf("rlp: nested lists are not supported here")
+			}
+			item = payload[:len(payload)-len(next)]
+		}
+		items = append(items, item)
+		payload = next
+	}
+	return items, nil
+}
+
+// rlpDecodeString decodes data as a single RLP string and returns its raw
+// content.
+func rlpDecodeString(data []byte) ([]byte, error) {
+	isList, payload, rest, err := rlpDecodeItem(data)
+	if err != nil {
+		return nil, err
+	}
+	if isList {
+		return nil, fmt.Errorf("rlp: expected a string, got a list")
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("rlp: trailing bytes after string")
+	}
+	return payload, nil
+}
@@ -0,0 +1,179 @@
+package lightproof
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Marshal and Unmarshal encode a Proof as CBOR (RFC 8949): a 7-element
+// array of [stateRoot, address, accountProof, storageHash, key, value,
+// storageProof], where accountProof/storageProof are themselves CBOR
+// arrays of byte strings. This keeps the wire format free of Go-specific
+// encodings (gob, go-ethereum's RLP types, ...) so proofs produced by this
+// repo can be verified by light clients written in other languages.
+const proofFieldCount = 7
+
+// Marshal encodes p as CBOR.
+func Marshal(p *Proof) ([]byte, error) {
+	var buf bytes.Buffer
+	writeArrayHeader(&buf, proofFieldCount)
+	writeByteString(&buf, p.StateRoot)
+	writeByteString(&buf, p.Address)
+	writeByteStringArray(&buf, p.AccountProof)
+	writeByteString(&buf, p.StorageHash)
+	writeByteString(&buf, p.Key)
+	writeByteString(&buf, p.Value)
+	writeByteStringArray(&buf, p.StorageProof)
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes a Proof previously produced by Marshal.
+func Unmarshal(data []byte) (*Proof, error) {
+	r := bytes.NewReader(data)
+	n, err := readArrayHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if n != proofFieldCount {
+		return nil, fmt.Errorf("cbor: expected %d fields, got %d", proofFieldCount, n)
+	}
+	p := &Proof{}
+	if p.StateRoot, err = readByteString(r); err != nil {
+		return nil, err
+	}
+	if p.Address, err = readByteString(r); err != nil {
+		return nil, err
+	}
+	if p.AccountProof, err = readByteStringArray(r); err != nil {
+		return nil, err
+	}
+	if p.StorageHash, err = readByteString(r); err != nil {
+		return nil, err
+	}
+	if p.Key, err = readByteString(r); err != nil {
+		return nil, err
+	}
+	if p.Value, err = readByteString(r); err != nil {
+		return nil, err
+	}
+	if p.StorageProof, err = readByteStringArray(r); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// CBOR major types used here: 2 (byte string) and 4 (array).
+const (
+	cborMajorByteString = 2 << 5
+	cborMajorArray      = 4 << 5
+)
+
+func writeHeader(buf *bytes.Buffer, major byte, n int) {
+	switch {
+	case n < 24:
+		buf.WriteByte(major | byte(n))
+	case n < 1<<8:
+		buf.WriteByte(major | 24)
+		buf.WriteByte(byte(n))
+	case n < 1<<16:
+		buf.WriteByte(major | 25)
+		var b [2]byte
+		binary.BigEndian.PutUint16(b[:], uint16(n))
+		buf.Write(b[:])
+	default:
+		buf.WriteByte(major | 26)
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(n))
+		buf.Write(b[:])
+	}
+}
+
+func writeArrayHeader(buf *bytes.Buffer, n int) { writeHeader(buf, cborMajorArray, n) }
+
+func writeByteString(buf *bytes.Buffer, b []byte) {
+	writeHeader(buf, cborMajorByteString, len(b))
+	buf.Write(b)
+}
+
+func writeByteStringArray(buf *bytes.Buffer, items [][]byte) {
+	writeArrayHeader(buf, len(items))
+	for _, item := range items {
+		writeByteString(buf, item)
+	}
+}
+
+func readHeader(r *bytes.Reader, wantMajor byte) (int, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	major := first & 0xe0
+	if major != wantMajor {
+		return 0, fmt.Errorf("cbor: unexpected major type %#x, expected %#x", major, wantMajor)
+	}
+	info := first & 0x1f
+	switch {
+	case info < 24:
+		return int(info), nil
+	case info == 24:
+		b, err := r.ReadByte()
+		return int(b), err
+	case info == 25:
+		var b [2]byte
+		if _, err := r.Read(b[:]); err != nil {
+			return 0, err
+		}
+		return int(binary.BigEndian.Uint16(b[:])), nil
+	case info == 26:
+		var b [4]byte
+		if _, err := r.Read(b[:]); err != nil {
+			return 0, err
+		}
+		return int(binary.BigEndian.Uint32(b[:])), nil
+	default:
+		return 0, fmt.Errorf("cbor: unsupported additional info %d", info)
+	}
+}
+
+func readArrayHeader(r *bytes.Reader) (int, error) { return readHeader(r, cborMajorArray) }
+
+func readByteString(r *bytes.Reader) ([]byte, error) {
+	n, err := readHeader(r, cborMajorByteString)
+	if err != nil {
+		return nil, err
+	}
+	// n comes straight from the wire and is untrusted: reject it up front
+	// if it claims more bytes than remain in the input, rather than
+	// allocating on its say-so and letting a short io.ReadFull surface the
+	// mismatch some other way.
+	if n > r.Len() {
+		return nil, fmt.Errorf("cbor: byte string length %d exceeds remaining input", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, fmt.Errorf("cbor: reading byte string: %w", err)
+	}
+	return b, nil
+}
+
+func readByteStringArray(r *bytes.Reader) ([][]byte, error) {
+	n, err := readArrayHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	// As in readByteString, n is untrusted: each array element is at least
+	// one byte on the wire, so an n exceeding the remaining input can never
+	// be satisfied and is rejected before allocating a slice of that size.
+	if n > r.Len() {
+		return nil, fmt.Errorf("cbor: array length %d exceeds remaining input", n)
+	}
+	items := make([][]byte, n)
+	for i := range items {
+		if items[i], err = readByteString(r); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,99 @@
+package lightproof
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// bytesToNibbles expands b into its individual 4-bit nibbles.
+func bytesToNibbles(b []byte) []byte {
+	nibbles := make([]byte, 0, len(b)*2)
+	for _, c := range b {
+		nibbles = append(nibbles, c>>4, c&0x0f)
+	}
+	return nibbles
+}
+
+// hexPrefixDecode decodes a compact (hex-prefix) encoded nibble path, as
+// used by leaf and extension trie nodes, returning the nibbles and
+// whether the terminator flag (leaf node) is set.
+func hexPrefixDecode(data []byte) (nibbles []byte, isLeaf bool) {
+	if len(data) == 0 {
+		return nil, false
+	}
+	first := data[0]
+	isLeaf = first&0x20 != 0
+	if first&0x10 != 0 {
+		nibbles = append(nibbles, first&0x0f)
+	}
+	for _, b := range data[1:] {
+		nibbles = append(nibbles, b>>4, b&0x0f)
+	}
+	return nibbles, isLeaf
+}
+
+// verifyProof walks a Merkle-Patricia-Trie inclusion/exclusion proof
+// against rootHash looking for key, returning the RLP-encoded value stored
+// at key and true if it is present, or (nil, false) if the proof instead
+// demonstrates that key is absent from the trie.
+//
+// ref tracks the reference to the next node to process: per the MPT spec
+// it is either a 32-byte keccak256 hash, in which case the matching node is
+// consumed from the flat proof array and checked against it, or (when the
+// referenced node's own RLP encoding is shorter than 32 bytes) the node's
+// raw encoding embedded directly in its parent, with no separate proof
+// array entry to consume.
+func verifyProof(rootHash, key []byte, proof [][]byte) ([]byte, bool, error) {
+	path := bytesToNibbles(keccak256(key))
+	ref := rootHash
+	proofIdx := 0
+	for {
+		var node []byte
+		if len(ref) == 32 {
+			if proofIdx >= len(proof) {
+				return nil, false, fmt.Errorf("proof exhausted before resolving the key")
+			}
+			node = proof[proofIdx]
+			if !bytes.Equal(keccak256(node), ref) {
+				return nil, false, fmt.Errorf("node %d: hash does not match its parent reference", proofIdx)
+			}
+			proofIdx++
+		} else {
+			node = ref
+		}
+		items, err := rlpDecodeNodeItems(node)
+		if err != nil {
+			return nil, false, fmt.Errorf("node %d: %w", proofIdx, err)
+		}
+		switch len(items) {
+		case 17:
+			if len(path) == 0 {
+				if len(items[16]) == 0 {
+					return nil, false, nil
+				}
+				return items[16], true, nil
+			}
+			next := items[path[0]]
+			path = path[1:]
+			if len(next) == 0 {
+				return nil, false, nil
+			}
+			ref = next
+		case 2:
+			nibbles, isLeaf := hexPrefixDecode(items[0])
+			if len(path) < len(nibbles) || !bytes.Equal(path[:len(nibbles)], nibbles) {
+				return nil, false, nil
+			}
+			path = path[len(nibbles):]
+			if isLeaf {
+				if len(path) != 0 {
+					return nil, false, fmt.Errorf("leaf node reached with unconsumed path")
+				}
+				return items[1], true, nil
+			}
+			ref = items[1]
+		default:
+			return nil, false, fmt.Errorf("node %d: unexpected item count %d", proofIdx, len(items))
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Package bytecode statically recovers the storage slot of a mapping used
+// by a contract's balanceOf(address), by disassembling the deployed
+// bytecode instead of brute-force iterating candidate slot indices. This
+// works for tokens whose balances mapping lives outside the numeric
+// brute-force range, and for slots nested inside a struct, as long as the
+// compiler emitted the conventional selector-dispatch -> SHA3 sequence.
+// Only the code deployed at the given address is analysed; the
+// implementation contract behind a proxy is not resolved.
+package bytecode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// balanceOfSelector is the 4-byte function selector for balanceOf(address).
+var balanceOfSelector = []byte{0x70, 0xa0, 0x82, 0x31}
+
+// Relevant EVM opcodes.
+const (
+	opPUSH1    = 0x60
+	opPUSH32   = 0x7f
+	opEQ       = 0x14
+	opJUMPI    = 0x57
+	opJUMPDEST = 0x5b
+	opMSTORE   = 0x52
+	opSHA3     = 0x20
+)
+
+// scratchSlotOffset is the memory offset solc stores the mapping's storage
+// slot number at when computing a mapping key's slot as keccak256(key .
+// slot): it lays out key at memory [0x00, 0x20) and slot at [0x20, 0x40),
+// then hashes the 0x40 bytes starting at 0.
+const scratchSlotOffset = 0x20
+
+// DiscoverSlot fetches contract's deployed bytecode and tries to statically
+// recover the storage slot of the mapping read by balanceOf(address): it
+// follows the selector-dispatch table to find the JUMPDEST balanceOf's
+// body actually starts at, then scans forward from there for the constant
+// solc stores to the scratch memory word it hashes together with the
+// mapping key (the slot number mixed into the mapping key). Callers should
+// fall back to brute-force iteration if this returns an error, and should
+// also re-verify the returned slot against a known balance before trusting
+// it, since a sufficiently unusual dispatcher or function body can still
+// defeat this heuristic.
+func DiscoverSlot(ctx context.Context, ethcli *ethclient.Client, contract common.Address) (int, error) {
+	code, err := ethcli.CodeAt(ctx, contract, nil)
+	if err != nil {
+		return -1, fmt.Errorf("CodeAt: %w", err)
+	}
+	if len(code) == 0 {
+		return -1, fmt.Errorf("contract has no deployed bytecode")
+	}
+	dest, err := findSelectorJumpTarget(code, balanceOfSelector)
+	if err != nil {
+		return -1, err
+	}
+	return scanForMappingSlot(code, dest)
+}
+
+// findSelectorJumpTarget walks code instruction by instruction (so pushed
+// immediates are never mis-decoded as opcodes) looking for the
+// solc-emitted dispatcher comparison for selector: `PUSH4 <selector> EQ
+// PUSHn <dest> JUMPI`, and returns the JUMPDEST offset execution lands at
+// when the call data's selector matches. Unlike a plain byte-string search
+// for the selector, which also matches inside unrelated PUSH immediates or
+// unrelated comparisons, this follows the actual control-flow edge the
+// EVM takes for this selector, landing on the real function body.
+func findSelectorJumpTarget(code []byte, selector []byte) (int, error) {
+	for i := 0; i < len(code); {
+		op := code[i]
+		if op < opPUSH1 || op > opPUSH32 {
+			i++
+			continue
+		}
+		n := int(op - opPUSH1 + 1)
+		if i+1+n > len(code) {
+			break
+		}
+		imm := code[i+1 : i+1+n]
+		if n == len(selector) && bytes.Equal(imm, selector) {
+			if dest, ok := matchDispatchTarget(code, i+1+n); ok {
+				return dest, nil
+			}
+		}
+		i += 1 + n
+	}
+	return -1, fmt.Errorf("balanceOf dispatch target not found in bytecode")
+}
+
+// matchDispatchTarget checks whether code at pos is the `EQ PUSHn <dest>
+// JUMPI` tail of a selector-dispatch comparison, and if so returns the
+// JUMPDEST offset it jumps to.
+func matchDispatchTarget(code []byte, pos int) (int, bool) {
+	if pos >= len(code) || code[pos] != opEQ {
+		return 0, false
+	}
+	pos++
+	if pos >= len(code) {
+		return 0, false
+	}
+	op := code[pos]
+	if op < opPUSH1 || op > opPUSH32 {
+		return 0, false
+	}
+	n := int(op - opPUSH1 + 1)
+	if pos+1+n >= len(code) || code[pos+1+n] != opJUMPI {
+		return 0, false
+	}
+	dest := int(new(big.Int).SetBytes(code[pos+1 : pos+1+n]).Int64())
+	if dest < 0 || dest >= len(code) || code[dest] != opJUMPDEST {
+		return 0, false
+	}
+	return dest, true
+}
+
+// scanForMappingSlot walks code starting at start looking for the
+// `PUSHn <slot> PUSH1 0x20 MSTORE` triple that stores the mapping's slot
+// number to the scratch-space word solc hashes together with the mapping
+// key, and returns <slot>. It stops and reports an error as soon as it
+// reaches the SHA3 that consumes that scratch space without having seen
+// the triple, since by then the function's key/slot computation is over.
+// It is a plain byte-slice scan, with no dependency on a live RPC
+// connection, so it can be exercised directly in tests.
+func scanForMappingSlot(code []byte, start int) (int, error) {
+	var prevPush, lastPush *int64
+	for i := start; i < len(code); {
+		op := code[i]
+		switch {
+		case op >= opPUSH1 && op <= opPUSH32:
+			n := int(op - opPUSH1 + 1)
+			if i+1+n > len(code) {
+				return -1, fmt.Errorf("could not locate mapping slot from bytecode")
+			}
+			value := new(big.Int).SetBytes(code[i+1 : i+1+n]).Int64()
+			prevPush, lastPush = lastPush, &value
+			i += 1 + n
+		case op == opMSTORE:
+			if prevPush != nil && lastPush != nil && *lastPush == scratchSlotOffset {
+				return int(*prevPush), nil
+			}
+			prevPush, lastPush = nil, nil
+			i++
+		case op == opSHA3:
+			return -1, fmt.Errorf("could not locate mapping slot from bytecode")
+		default:
+			prevPush, lastPush = nil, nil
+			i++
+		}
+	}
+	return -1, fmt.Errorf("could not locate mapping slot from bytecode")
+}
@@ -0,0 +1,124 @@
+package bytecode
+
+import "testing"
+
+// pushOp builds a PUSHn instruction pushing value, encoded with the
+// minimal number of bytes (at least 1).
+func pushOp(value byte) []byte {
+	return []byte{opPUSH1, value}
+}
+
+// mappingKeySlotCode builds the bytecode solc emits to compute
+// keccak256(key . slot) for a mapping lookup: it stores key at scratch
+// memory [0x00, 0x20), slot at [0x20, 0x40), then hashes those 0x40 bytes.
+func mappingKeySlotCode(key, slot byte) []byte {
+	code := pushOp(key)
+	code = append(code, pushOp(0x00)...)
+	code = append(code, opMSTORE)
+	code = append(code, pushOp(slot)...)
+	code = append(code, pushOp(0x20)...)
+	code = append(code, opMSTORE)
+	code = append(code, pushOp(0x40)...)
+	code = append(code, pushOp(0x00)...)
+	code = append(code, opSHA3)
+	return code
+}
+
+func TestScanForMappingSlotSkipsSelectorImmediate(t *testing.T) {
+	// Selector bytes (0x70 0xa0 0x82 0x31) immediately followed by the real
+	// key/slot hashing code. Starting the scan at the selector's own bytes
+	// (as the pre-fix code did) would decode 0x70 as PUSH17 and desync past
+	// the real instructions entirely.
+	selector := []byte{0x70, 0xa0, 0x82, 0x31}
+	code := append(append([]byte{}, selector...), mappingKeySlotCode(0xaa, 0x05)...)
+
+	slot, err := scanForMappingSlot(code, len(selector))
+	if err != nil {
+		t.Fatalf("scanForMappingSlot: %v", err)
+	}
+	if slot != 5 {
+		t.Errorf("slot = %d, want 5", slot)
+	}
+}
+
+func TestScanForMappingSlotNoSHA3(t *testing.T) {
+	code := pushOp(0x05)
+	if _, err := scanForMappingSlot(code, 0); err == nil {
+		t.Fatal("expected an error when no SHA3 opcode is present")
+	}
+}
+
+func TestScanForMappingSlotIgnoresSha3MemoryOffset(t *testing.T) {
+	// Regression test for the original bug: the last PUSH before SHA3 is
+	// the memory offset operand (0x00), not the mapping slot. A scan that
+	// just grabs "the constant pushed immediately before SHA3" would wrongly
+	// return 0 here instead of the real slot, 5.
+	code := mappingKeySlotCode(0xaa, 0x05)
+
+	slot, err := scanForMappingSlot(code, 0)
+	if err != nil {
+		t.Fatalf("scanForMappingSlot: %v", err)
+	}
+	if slot != 5 {
+		t.Errorf("slot = %d, want 5, not the SHA3 memory offset", slot)
+	}
+}
+
+func TestFindSelectorJumpTargetFollowsDispatch(t *testing.T) {
+	// DUP1 PUSH4 <selector> EQ PUSH1 <dest> JUMPI ... JUMPDEST <key/slot code>
+	selector := []byte{0x70, 0xa0, 0x82, 0x31}
+	code := []byte{0x80} // DUP1, irrelevant to the scan but present in real dispatchers
+	code = append(code, 0x63)
+	code = append(code, selector...) // PUSH4 <selector>
+	code = append(code, opEQ)
+	dest := byte(len(code) + 3) // PUSH1 <dest> JUMPI land right after this instruction
+	code = append(code, opPUSH1, dest, opJUMPI)
+	code = append(code, opJUMPDEST)
+	code = append(code, mappingKeySlotCode(0xaa, 0x05)...)
+
+	got, err := findSelectorJumpTarget(code, selector)
+	if err != nil {
+		t.Fatalf("findSelectorJumpTarget: %v", err)
+	}
+	if got != int(dest) {
+		t.Fatalf("findSelectorJumpTarget = %d, want %d", got, dest)
+	}
+	if code[got] != opJUMPDEST {
+		t.Fatalf("target offset %d is not a JUMPDEST", got)
+	}
+
+	slot, err := scanForMappingSlot(code, got)
+	if err != nil {
+		t.Fatalf("scanForMappingSlot: %v", err)
+	}
+	if slot != 5 {
+		t.Errorf("slot = %d, want 5", slot)
+	}
+}
+
+func TestFindSelectorJumpTargetIgnoresBareSelectorBytes(t *testing.T) {
+	// The selector appears, but not as a PUSH4 immediate followed by the
+	// EQ/PUSHn/JUMPI dispatch tail - e.g. it's sitting inside unrelated
+	// PUSH32 data. This must not be mistaken for a real dispatch edge.
+	selector := []byte{0x70, 0xa0, 0x82, 0x31}
+	data := make([]byte, 32)
+	copy(data[10:], selector)
+	code := append([]byte{opPUSH32}, data...)
+
+	if _, err := findSelectorJumpTarget(code, selector); err == nil {
+		t.Fatal("expected an error when the selector is not a real dispatch comparison")
+	}
+}
+
+func TestScanForMappingSlotDesyncsWhenStartedOnSelector(t *testing.T) {
+	// Regression test for the original bug: scanning starting at the
+	// selector bytes themselves (rather than after them) decodes 0x70 as
+	// PUSH17, consumes the next 17 bytes as immediate data, and never
+	// reaches the genuine key/slot hashing code.
+	selector := []byte{0x70, 0xa0, 0x82, 0x31}
+	code := append(append([]byte{}, selector...), mappingKeySlotCode(0xaa, 0x05)...)
+
+	if _, err := scanForMappingSlot(code, 0); err == nil {
+		t.Fatal("expected scanning from the selector bytes to desync and fail to find a slot")
+	}
+}
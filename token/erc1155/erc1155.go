@@ -0,0 +1,147 @@
+// Package erc1155 fetches and verifies storage proofs of NFT balances for
+// ERC1155 tokens, which keep balances on a nested map
+// `tokenId => holder => balance` (the `_balances` mapping in OpenZeppelin's
+// reference implementation). This lets voting or airdrops built for
+// semi-fungible token collections reuse the same proof pipeline already
+// available for ERC20 tokens.
+package erc1155
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/vocdoni/storage-proofs-eth-go/ethstorageproof"
+	"github.com/vocdoni/storage-proofs-eth-go/helpers"
+	"github.com/vocdoni/storage-proofs-eth-go/token/erc20"
+)
+
+const (
+	// DiscoveryIterations is the amount of storage indices tried when
+	// brute-forcing the `_balances` slot.
+	DiscoveryIterations = 30
+)
+
+// ErrSlotNotFound represents the storage slot not found error
+var ErrSlotNotFound = errors.New("storage slot not found")
+
+// ERC1155 tokens keep balances on a nested map `tokenId => holder =>
+// balance` (the `_balances` mapping in OpenZeppelin's reference
+// implementation).
+type ERC1155 struct {
+	erc20 *erc20.ERC20Token
+}
+
+func (e *ERC1155) Init(tokenAddress, web3endpoint string) error {
+	e.erc20 = &erc20.ERC20Token{}
+	return e.erc20.Init(context.Background(), web3endpoint, tokenAddress)
+}
+
+func (e *ERC1155) GetBlock(block *big.Int) (*types.Block, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	return e.erc20.GetBlock(ctx, block)
+}
+
+// balanceSlot computes the EVM storage slot for _balances[tokenID][holder],
+// given the index slot of the _balances mapping itself. The outer key
+// (tokenID) is hashed against islot exactly like a regular map slot, and
+// the inner key (holder) is then hashed against the result.
+func balanceSlot(holder common.Address, tokenID *big.Int, islot int) (common.Hash, error) {
+	outer, err := helpers.GetMapSlot(common.BigToHash(tokenID).Hex(), islot)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	buf := append(common.LeftPadBytes(holder.Bytes(), 32), outer.Bytes()...)
+	return crypto.Keccak256Hash(buf), nil
+}
+
+// GetBalanceProof returns the storage merkle proof for holder's balance of
+// tokenID.
+func (e *ERC1155) GetBalanceProof(holder common.Address, tokenID *big.Int,
+	block *big.Int, islot int) (*ethstorageproof.StorageProof, error) {
+	blockData, err := e.GetBlock(block)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	return e.getBalanceProofWithIndexSlot(ctx, holder, tokenID, blockData, islot)
+}
+
+// getBalanceProofWithIndexSlot returns the storage merkle proof for
+// holder's balance of tokenID. The index slot is the position of the
+// `_balances` mapping on the EVM storage sub-trie for the contract. If the
+// index slot is unknown, GetBalanceProof() can be used instead, after
+// calling DiscoverBalanceSlot.
+func (e *ERC1155) getBalanceProofWithIndexSlot(ctx context.Context, holder common.Address,
+	tokenID *big.Int, block *types.Block, islot int) (*ethstorageproof.StorageProof, error) {
+	slot, err := balanceSlot(holder, tokenID, islot)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{fmt.Sprintf("%x", slot)}
+	if block == nil {
+		block, err = e.erc20.GetBlock(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			return nil, fmt.Errorf("cannot fetch block info")
+		}
+	}
+	return e.erc20.GetProof(ctx, keys, block)
+}
+
+// DiscoverBalanceSlot tries to find the EVM storage index slot of the
+// `_balances` mapping. tokenID, holder and the known expectedBalance must
+// be provided so the value read from storage can be compared against it.
+// Returns ErrSlotNotFound if the slot cannot be found.
+func (e *ERC1155) DiscoverBalanceSlot(holder common.Address, tokenID *big.Int,
+	expectedBalance uint64) (int, error) {
+	addr := common.Address{}
+	copy(addr[:], e.erc20.TokenAddr[:20])
+
+	index := -1
+	for i := 0; i < DiscoveryIterations; i++ {
+		slot, err := balanceSlot(holder, tokenID, i)
+		if err != nil {
+			return -1, fmt.Errorf("GetSlot: %w", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		value, err := e.erc20.Ethcli.StorageAt(ctx, addr, slot, nil)
+		cancel()
+		if err != nil {
+			return -1, err
+		}
+		if new(big.Int).SetBytes(value).Uint64() == expectedBalance {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return -1, ErrSlotNotFound
+	}
+	return index, nil
+}
+
+// VerifyBalanceProof checks that the storage proof attests that holder
+// held amount units of tokenID at the block the proof was taken from.
+func VerifyBalanceProof(amount *big.Int, storageProof *ethstorageproof.StorageProof) error {
+	if len(storageProof.StorageProof) == 0 {
+		return fmt.Errorf("empty storage proof")
+	}
+	got := storageProof.StorageProof[0].Value
+	if got == nil {
+		got = new(big.Int)
+	}
+	if got.Cmp(amount) != 0 {
+		return fmt.Errorf("balance mismatch: got %s, expected %s", got.String(), amount.String())
+	}
+	return nil
+}
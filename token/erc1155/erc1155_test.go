@@ -0,0 +1,63 @@
+package erc1155
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/storage-proofs-eth-go/ethstorageproof"
+)
+
+func TestBalanceSlotChangesWithInputs(t *testing.T) {
+	holder := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	tokenID := big.NewInt(42)
+
+	base, err := balanceSlot(holder, tokenID, 0)
+	if err != nil {
+		t.Fatalf("balanceSlot: %v", err)
+	}
+	if other, err := balanceSlot(holder, big.NewInt(43), 0); err != nil {
+		t.Fatalf("balanceSlot: %v", err)
+	} else if base == other {
+		t.Error("changing the tokenID should change the computed slot")
+	}
+	if other, err := balanceSlot(holder, tokenID, 1); err != nil {
+		t.Fatalf("balanceSlot: %v", err)
+	} else if base == other {
+		t.Error("changing the index slot should change the computed slot")
+	}
+	other := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	if otherSlot, err := balanceSlot(other, tokenID, 0); err != nil {
+		t.Fatalf("balanceSlot: %v", err)
+	} else if base == otherSlot {
+		t.Error("changing the holder should change the computed slot")
+	}
+}
+
+func TestVerifyBalanceProofMatch(t *testing.T) {
+	amount := big.NewInt(7)
+	proof := &ethstorageproof.StorageProof{
+		StorageProof: []ethstorageproof.StorageResult{{Value: big.NewInt(7)}},
+	}
+	if err := VerifyBalanceProof(amount, proof); err != nil {
+		t.Fatalf("VerifyBalanceProof: %v", err)
+	}
+}
+
+func TestVerifyBalanceProofMismatch(t *testing.T) {
+	amount := big.NewInt(7)
+	proof := &ethstorageproof.StorageProof{
+		StorageProof: []ethstorageproof.StorageResult{{Value: big.NewInt(8)}},
+	}
+	if err := VerifyBalanceProof(amount, proof); err == nil {
+		t.Fatal("expected a mismatched balance to fail verification")
+	}
+}
+
+func TestVerifyBalanceProofEmpty(t *testing.T) {
+	amount := big.NewInt(7)
+	proof := &ethstorageproof.StorageProof{}
+	if err := VerifyBalanceProof(amount, proof); err == nil {
+		t.Fatal("expected an empty storage proof to fail verification")
+	}
+}
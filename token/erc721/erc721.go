@@ -0,0 +1,134 @@
+// Package erc721 fetches and verifies storage proofs of NFT ownership for
+// ERC721 tokens that keep owners on an `_owners` map `tokenId => address`,
+// the layout used by OpenZeppelin's ERC721 implementation. This lets
+// voting or airdrops built for NFT collections reuse the same proof
+// pipeline already available for ERC20 tokens.
+package erc721
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/vocdoni/storage-proofs-eth-go/ethstorageproof"
+	"github.com/vocdoni/storage-proofs-eth-go/helpers"
+	"github.com/vocdoni/storage-proofs-eth-go/token/erc20"
+)
+
+const (
+	// DiscoveryIterations is the amount of storage indices tried when
+	// brute-forcing the `_owners` slot.
+	DiscoveryIterations = 30
+)
+
+// ErrSlotNotFound represents the storage slot not found error
+var ErrSlotNotFound = errors.New("storage slot not found")
+
+// ErrOwnerMismatch is returned when the owner recovered from storage does
+// not match the expected holder.
+var ErrOwnerMismatch = errors.New("owner does not match expected holder")
+
+// ERC721 tokens keep ownership on a map `tokenId => address` (the `_owners`
+// mapping in OpenZeppelin's reference implementation).
+type ERC721 struct {
+	erc20 *erc20.ERC20Token
+}
+
+func (e *ERC721) Init(tokenAddress, web3endpoint string) error {
+	e.erc20 = &erc20.ERC20Token{}
+	return e.erc20.Init(context.Background(), web3endpoint, tokenAddress)
+}
+
+func (e *ERC721) GetBlock(block *big.Int) (*types.Block, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	return e.erc20.GetBlock(ctx, block)
+}
+
+// GetOwnerProof returns the storage merkle proof for the owner of tokenID.
+func (e *ERC721) GetOwnerProof(tokenID *big.Int,
+	block *big.Int, islot int) (*ethstorageproof.StorageProof, error) {
+	blockData, err := e.GetBlock(block)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	return e.getOwnerProofWithIndexSlot(ctx, tokenID, blockData, islot)
+}
+
+// getOwnerProofWithIndexSlot returns the storage merkle proof for the owner
+// of tokenID. The index slot is the position of the `_owners` mapping on
+// the EVM storage sub-trie for the contract. If the index slot is unknown,
+// GetOwnerProof() can be used instead, after calling DiscoverOwnerSlot.
+func (e *ERC721) getOwnerProofWithIndexSlot(ctx context.Context, tokenID *big.Int,
+	block *types.Block, islot int) (*ethstorageproof.StorageProof, error) {
+	slot, err := helpers.GetMapSlot(common.BigToHash(tokenID).Hex(), islot)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{fmt.Sprintf("%x", slot)}
+	if block == nil {
+		block, err = e.erc20.GetBlock(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			return nil, fmt.Errorf("cannot fetch block info")
+		}
+	}
+	return e.erc20.GetProof(ctx, keys, block)
+}
+
+// DiscoverOwnerSlot tries to find the EVM storage index slot of the
+// `_owners` mapping. tokenID and its known expectedOwner must be provided
+// so the value read from storage can be compared against it.
+// Returns ErrSlotNotFound if the slot cannot be found.
+func (e *ERC721) DiscoverOwnerSlot(tokenID *big.Int,
+	expectedOwner common.Address) (int, error) {
+	addr := common.Address{}
+	copy(addr[:], e.erc20.TokenAddr[:20])
+
+	index := -1
+	for i := 0; i < DiscoveryIterations; i++ {
+		slot, err := helpers.GetMapSlot(common.BigToHash(tokenID).Hex(), i)
+		if err != nil {
+			return -1, fmt.Errorf("GetSlot: %w", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		value, err := e.erc20.Ethcli.StorageAt(ctx, addr, slot, nil)
+		cancel()
+		if err != nil {
+			return -1, err
+		}
+		owner := common.BytesToAddress(value)
+		if owner == expectedOwner {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return -1, ErrSlotNotFound
+	}
+	return index, nil
+}
+
+// VerifyOwnerProof checks that the storage proof attests that owner held
+// tokenID at the block the proof was taken from.
+func VerifyOwnerProof(owner common.Address, storageProof *ethstorageproof.StorageProof) error {
+	if len(storageProof.StorageProof) == 0 {
+		return fmt.Errorf("empty storage proof")
+	}
+	var got common.Address
+	if value := storageProof.StorageProof[0].Value; value != nil {
+		got = common.BytesToAddress(value.Bytes())
+	}
+	if got != owner {
+		return fmt.Errorf("%w: got %s, expected %s", ErrOwnerMismatch, got.Hex(), owner.Hex())
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package erc721
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/storage-proofs-eth-go/ethstorageproof"
+)
+
+func TestVerifyOwnerProofMatch(t *testing.T) {
+	owner := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	proof := &ethstorageproof.StorageProof{
+		StorageProof: []ethstorageproof.StorageResult{
+			{Value: new(big.Int).SetBytes(owner.Bytes())},
+		},
+	}
+	if err := VerifyOwnerProof(owner, proof); err != nil {
+		t.Fatalf("VerifyOwnerProof: %v", err)
+	}
+}
+
+func TestVerifyOwnerProofMismatch(t *testing.T) {
+	owner := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	other := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	proof := &ethstorageproof.StorageProof{
+		StorageProof: []ethstorageproof.StorageResult{
+			{Value: new(big.Int).SetBytes(other.Bytes())},
+		},
+	}
+	if err := VerifyOwnerProof(owner, proof); err == nil {
+		t.Fatal("expected a mismatched owner to fail verification")
+	}
+}
+
+func TestVerifyOwnerProofEmpty(t *testing.T) {
+	owner := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	proof := &ethstorageproof.StorageProof{}
+	if err := VerifyOwnerProof(owner, proof); err == nil {
+		t.Fatal("expected an empty storage proof to fail verification")
+	}
+}
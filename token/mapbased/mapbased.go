@@ -5,33 +5,69 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/vocdoni/storage-proofs-eth-go/ethstorageproof"
 	"github.com/vocdoni/storage-proofs-eth-go/helpers"
+	"github.com/vocdoni/storage-proofs-eth-go/internal/proofbatch"
+	"github.com/vocdoni/storage-proofs-eth-go/token/bytecode"
 	"github.com/vocdoni/storage-proofs-eth-go/token/erc20"
 )
 
 const (
 	DiscoveryIterations = 30
+
+	// maxKeysPerProofCall caps how many storage keys are requested in a
+	// single eth_getProof call, to stay under common RPC provider limits.
+	maxKeysPerProofCall = 1000
+
+	// batchWorkers is the amount of eth_getProof calls GetProofBatch keeps
+	// in flight concurrently once the holder set spans several chunks.
+	batchWorkers = 8
 )
 
 // ErrSlotNotFound represents the storage slot not found error
 var ErrSlotNotFound = errors.New("storage slot not found")
 
+// ErrNotZero is returned by GetZeroProof when the holder turns out to hold
+// a nonzero balance at the requested slot.
+var ErrNotZero = errors.New("holder does not hold a zero balance at this slot")
+
 // Mapbased tokens are those where the balance is stored on a map `address => uint256`.
 // Most of ERC20 tokens follows this approach.
 type Mapbased struct {
-	erc20 *erc20.ERC20Token
+	erc20             *erc20.ERC20Token
+	islot             int
+	tokenAddress      string
+	web3endpoint      string
+	fallbackEndpoints []string
 }
 
 func (m *Mapbased) Init(tokenAddress, web3endpoint string) error {
 	m.erc20 = &erc20.ERC20Token{}
+	m.tokenAddress = tokenAddress
+	m.web3endpoint = web3endpoint
 	return m.erc20.Init(context.Background(), web3endpoint, tokenAddress)
 }
 
+// SetFallbackEndpoints configures additional web3 RPC endpoints that
+// BalanceAt and ProofAt retry against, in order, when the current endpoint
+// turns out not to be an archive node for the requested block.
+func (m *Mapbased) SetFallbackEndpoints(endpoints []string) {
+	m.fallbackEndpoints = endpoints
+}
+
+// SetSlot configures the EVM storage index slot manually, bypassing
+// DiscoverSlot. This is required to produce a zero proof for a holder,
+// since DiscoverSlot needs a nonzero balance to compare against and has
+// nothing to search for when the holder's balance is zero.
+func (m *Mapbased) SetSlot(islot int) {
+	m.islot = islot
+}
+
 func (m *Mapbased) GetBlock(block *big.Int) (*types.Block, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
@@ -50,6 +86,33 @@ func (m *Mapbased) GetProof(holder common.Address,
 	return m.getMapProofWithIndexSlot(ctx, holder, blockData, islot)
 }
 
+// GetZeroProof returns a storage merkle proof attesting that holder holds a
+// zero balance at block, for the given storage slot (a proof of exclusion,
+// since an untouched storage slot is never populated in the trie). Pass
+// islot=-1 to reuse the slot configured via SetSlot. Returns ErrNotZero if
+// the holder turns out to hold a nonzero balance at that slot.
+func (m *Mapbased) GetZeroProof(holder common.Address,
+	block *big.Int, islot int) (*ethstorageproof.StorageProof, error) {
+	if islot < 0 {
+		islot = m.islot
+	}
+	blockData, err := m.GetBlock(block)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	sproof, err := m.getMapProofWithIndexSlot(ctx, holder, blockData, islot)
+	if err != nil {
+		return nil, err
+	}
+	if len(sproof.StorageProof) == 0 || sproof.StorageProof[0].Value == nil ||
+		sproof.StorageProof[0].Value.Sign() != 0 {
+		return nil, ErrNotZero
+	}
+	return sproof, nil
+}
+
 // getMapProofWithIndexSlot returns the storage merkle proofs for the acount holder.
 // The index slot is the position on the EVM storage sub-trie for the contract.
 // If index slot is unknown, GetProof() could be used instead to try to find it
@@ -72,12 +135,92 @@ func (m *Mapbased) getMapProofWithIndexSlot(ctx context.Context, holder common.A
 	return m.erc20.GetProof(ctx, keys, block)
 }
 
-// DiscoverSlot tries to find the EVM storage index slot.
-// A token holder address must be provided in order to have a balance to search and compare.
-// Returns ErrSlotNotFound if the slot cannot be found.
-// If found, returns also the amount stored.
+// GetProofBatch returns one storage merkle proof per holder for the given
+// slot, fetched with as few eth_getProof round-trips as possible: all
+// storage keys for a chunk of holders are requested in a single RPC call
+// (the JSON-RPC eth_getProof method accepts a `keys` array), and chunks are
+// pipelined concurrently across a worker pool once the holder set is
+// larger than maxKeysPerProofCall. This replaces the one-key-per-round-trip
+// behaviour of getMapProofWithIndexSlot, which is prohibitively slow for
+// census generation over thousands of holders.
+func (m *Mapbased) GetProofBatch(holders []common.Address,
+	block *big.Int, slot int) ([]*ethstorageproof.StorageProof, error) {
+	blockData, err := m.GetBlock(block)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, len(holders))
+	for i, holder := range holders {
+		hslot, err := helpers.GetMapSlot(holder.Hex(), slot)
+		if err != nil {
+			return nil, fmt.Errorf("GetMapSlot for %s: %w", holder.Hex(), err)
+		}
+		keys[i] = fmt.Sprintf("%x", hslot)
+	}
+
+	numChunks := proofbatch.NumProofChunks(len(keys), maxKeysPerProofCall)
+	chunkProofs := make([]*ethstorageproof.StorageProof, numChunks)
+	chunkErrs := make([]error, numChunks)
+
+	jobs := make(chan int, numChunks)
+	for i := 0; i < numChunks; i++ {
+		jobs <- i
+	}
+	close(jobs)
+
+	workers := batchWorkers
+	if numChunks < workers {
+		workers = numChunks
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				start, end := proofbatch.ProofChunkBounds(i, len(keys), maxKeysPerProofCall)
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+				chunkProofs[i], chunkErrs[i] = m.erc20.GetProof(ctx, keys[start:end], blockData)
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	proofs := make([]*ethstorageproof.StorageProof, 0, len(holders))
+	for i, proof := range chunkProofs {
+		if chunkErrs[i] != nil {
+			return nil, fmt.Errorf("chunk %d: %w", i, chunkErrs[i])
+		}
+		start, end := proofbatch.ProofChunkBounds(i, len(keys), maxKeysPerProofCall)
+		if len(proof.StorageProof) != end-start {
+			return nil, fmt.Errorf("chunk %d: expected %d storage proofs, got %d",
+				i, end-start, len(proof.StorageProof))
+		}
+		for j := range proof.StorageProof {
+			proofs = append(proofs, &ethstorageproof.StorageProof{
+				Address:      proof.Address,
+				AccountProof: proof.AccountProof,
+				Balance:      proof.Balance,
+				CodeHash:     proof.CodeHash,
+				Nonce:        proof.Nonce,
+				StorageHash:  proof.StorageHash,
+				StorageProof: []ethstorageproof.StorageResult{proof.StorageProof[j]},
+			})
+		}
+	}
+	return proofs, nil
+}
+
+// DiscoverSlot tries to find the EVM storage index slot. It first attempts
+// static bytecode analysis (see token/bytecode), which works without
+// iterating DiscoveryIterations and can find slots outside that range; if
+// that fails, it falls back to brute-force iteration comparing against a
+// known holder balance. A token holder address must be provided in order
+// to have a balance to search and compare. Returns ErrSlotNotFound if the
+// slot cannot be found. If found, returns also the amount stored.
 func (m *Mapbased) DiscoverSlot(holder common.Address) (int, *big.Float, error) {
-	var slot [32]byte
 	tokenData, err := m.erc20.GetTokenData()
 	if err != nil {
 		return -1, nil, fmt.Errorf("GetTokenData: %w", err)
@@ -86,40 +229,96 @@ func (m *Mapbased) DiscoverSlot(holder common.Address) (int, *big.Float, error)
 	if err != nil {
 		return -1, nil, fmt.Errorf("Balance: %w", err)
 	}
+	ubalance, _ := balance.Uint64()
 
 	addr := common.Address{}
 	copy(addr[:], m.erc20.TokenAddr[:20])
+	decimals := int(tokenData.Decimals)
 
-	ubalance, _ := balance.Uint64()
-	amount := big.NewFloat(0)
-	index := -1
-	for i := 0; i < DiscoveryIterations; i++ {
-		// Prepare storage index
-		slot, err = helpers.GetMapSlot(holder.Hex(), i)
-		if err != nil {
-			return index, nil, fmt.Errorf("GetSlot: %w", err)
-		}
-		// Get Storage
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-		value, err := m.erc20.Ethcli.StorageAt(ctx, addr, slot, nil)
-		cancel()
-		if err != nil {
-			return index, nil, err
+	if bslot, err := bytecode.DiscoverSlot(context.Background(), m.erc20.Ethcli, addr); err == nil {
+		if amount, ok, err := m.checkSlot(addr, holder, bslot, ubalance, decimals); err != nil {
+			return -1, nil, err
+		} else if ok {
+			return bslot, amount, nil
 		}
+	}
 
-		// Parse balance value
-		amount, err := helpers.ValueToBalance(fmt.Sprintf("%x", value), int(tokenData.Decimals))
+	for i := 0; i < DiscoveryIterations; i++ {
+		amount, ok, err := m.checkSlot(addr, holder, i, ubalance, decimals)
 		if err != nil {
-			continue
+			return -1, nil, err
 		}
-		// Check if balance matches
-		if a, _ := amount.Uint64(); a == ubalance {
-			index = i
-			break
+		if ok {
+			return i, amount, nil
 		}
 	}
-	if index == -1 {
-		return index, nil, ErrSlotNotFound
+	return -1, nil, ErrSlotNotFound
+}
+
+// checkSlot reads the value stored at islot for holder and reports whether
+// it matches ubalance, returning the parsed amount when it does. err is
+// non-nil only when the lookup itself failed (e.g. an RPC error); a nil
+// error with ok=false means the slot was read successfully but holds the
+// wrong value.
+func (m *Mapbased) checkSlot(addr, holder common.Address,
+	islot int, ubalance uint64, decimals int) (amount *big.Float, ok bool, err error) {
+	slot, err := helpers.GetMapSlot(holder.Hex(), islot)
+	if err != nil {
+		return nil, false, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	value, err := m.erc20.Ethcli.StorageAt(ctx, addr, slot, nil)
+	cancel()
+	if err != nil {
+		return nil, false, fmt.Errorf("StorageAt: %w", err)
+	}
+	amount, err = helpers.ValueToBalance(fmt.Sprintf("%x", value), decimals)
+	if err != nil {
+		return nil, false, err
+	}
+	if a, _ := amount.Uint64(); a != ubalance {
+		return nil, false, nil
+	}
+	return amount, true, nil
+}
+
+// proofAt is the shared implementation behind ProofAt and BalanceAt. It
+// additionally returns the Mapbased instance that actually served the
+// proof, so BalanceAt can fetch token metadata (decimals) from the
+// endpoint that worked rather than always redialing the original one.
+func (m *Mapbased) proofAt(holder common.Address, block *big.Int, slot int) (*ethstorageproof.StorageProof, *Mapbased, error) {
+	endpoints := append([]string{m.web3endpoint}, m.fallbackEndpoints...)
+	sproof, prover, err := proofbatch.ProofAt(m, func() proofbatch.Prover { return &Mapbased{} },
+		m.tokenAddress, endpoints, holder, block, slot)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sproof, prover.(*Mapbased), nil
+}
+
+// ProofAt returns a storage merkle proof for holder at block and slot,
+// probing the primary endpoint first (reusing m's existing connection) and
+// then, if it turns out not to be an archive node for that block, each
+// endpoint configured via SetFallbackEndpoints in order. Returns a
+// *proofbatch.EndpointsExhaustedError if none of them can serve the proof.
+func (m *Mapbased) ProofAt(holder common.Address, block *big.Int, slot int) (*ethstorageproof.StorageProof, error) {
+	sproof, _, err := m.proofAt(holder, block, slot)
+	return sproof, err
+}
+
+// BalanceAt returns holder's token balance at block, using the same
+// archive-node fallback behaviour as ProofAt.
+func (m *Mapbased) BalanceAt(holder common.Address, block *big.Int, slot int) (*big.Float, error) {
+	sproof, mm, err := m.proofAt(holder, block, slot)
+	if err != nil {
+		return nil, err
+	}
+	tokenData, err := mm.erc20.GetTokenData()
+	if err != nil {
+		return nil, fmt.Errorf("GetTokenData: %w", err)
+	}
+	if len(sproof.StorageProof) == 0 || sproof.StorageProof[0].Value == nil {
+		return big.NewFloat(0), nil
 	}
-	return index, amount, nil
+	return helpers.ValueToBalance(sproof.StorageProof[0].Value.String(), int(tokenData.Decimals))
 }
@@ -0,0 +1,489 @@
+// Package minime fetches and verifies storage proofs for MiniMeToken-style
+// contracts (used by Giveth, Aragon and others), which keep a full history
+// of balances per holder as an array of checkpoints, `address =>
+// Checkpoint[]`. Each checkpoint packs a `fromBlock` and a `value` into a
+// single 256 bit storage word, and a proof always targets the most recent
+// checkpoint at or before the requested block.
+package minime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/vocdoni/storage-proofs-eth-go/ethstorageproof"
+	"github.com/vocdoni/storage-proofs-eth-go/helpers"
+	"github.com/vocdoni/storage-proofs-eth-go/internal/proofbatch"
+	"github.com/vocdoni/storage-proofs-eth-go/token/bytecode"
+	"github.com/vocdoni/storage-proofs-eth-go/token/erc20"
+)
+
+const (
+	DiscoveryIterations = 30
+
+	// maxKeysPerProofCall caps how many storage keys are requested in a
+	// single eth_getProof call, to stay under common RPC provider limits.
+	maxKeysPerProofCall = 1000
+
+	// batchWorkers is the amount of eth_getProof calls GetProofBatch keeps
+	// in flight concurrently once the holder set spans several chunks.
+	batchWorkers = 8
+)
+
+// ErrSlotNotFound represents the storage slot not found error
+var ErrSlotNotFound = errors.New("storage slot not found")
+
+// ErrNotZero is returned by GetZeroProof when the storage word it proves
+// for the holder at the requested slot is nonzero.
+var ErrNotZero = errors.New("holder does not hold a zero balance at this slot")
+
+// ErrCheckpointMismatch is returned by VerifyProof when the checkpoint
+// encoded by the storage proof does not match the claimed balance/block.
+var ErrCheckpointMismatch = errors.New("checkpoint value does not match the claimed balance")
+
+// Minime tokens keep balances as a mapping `address => Checkpoint[]`.
+type Minime struct {
+	erc20             *erc20.ERC20Token
+	islot             int
+	tokenAddress      string
+	web3endpoint      string
+	fallbackEndpoints []string
+}
+
+func (m *Minime) Init(tokenAddress, web3endpoint string) error {
+	m.erc20 = &erc20.ERC20Token{}
+	m.tokenAddress = tokenAddress
+	m.web3endpoint = web3endpoint
+	return m.erc20.Init(context.Background(), web3endpoint, tokenAddress)
+}
+
+// SetFallbackEndpoints configures additional web3 RPC endpoints that
+// BalanceAt and ProofAt retry against, in order, when the current endpoint
+// turns out not to be an archive node for the requested block.
+func (m *Minime) SetFallbackEndpoints(endpoints []string) {
+	m.fallbackEndpoints = endpoints
+}
+
+func (m *Minime) GetBlock(block *big.Int) (*types.Block, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	return m.erc20.GetBlock(ctx, block)
+}
+
+// SetSlot configures the EVM storage index slot manually, bypassing
+// DiscoverSlot. This is required to produce a zero proof for a holder,
+// since DiscoverSlot needs a nonzero balance to compare against and has
+// nothing to search for when the holder has no checkpoints.
+func (m *Minime) SetSlot(islot int) {
+	m.islot = islot
+}
+
+// GetProof returns the storage merkle proof for the holder's most recent
+// balance checkpoint.
+func (m *Minime) GetProof(holder common.Address,
+	block *big.Int, islot int) (*ethstorageproof.StorageProof, error) {
+	blockData, err := m.GetBlock(block)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	return m.getCheckpointProof(ctx, holder, blockData, islot)
+}
+
+// getCheckpointProof returns the storage merkle proof for the holder's
+// balance checkpoint that is most recent as of block. The index slot is the
+// position of the balances mapping on the EVM storage sub-trie for the
+// contract. If index slot is unknown, GetProof() could be used instead to
+// try to find it.
+func (m *Minime) getCheckpointProof(ctx context.Context, holder common.Address,
+	block *types.Block, islot int) (*ethstorageproof.StorageProof, error) {
+	var err error
+	if block == nil {
+		block, err = m.erc20.GetBlock(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			return nil, fmt.Errorf("cannot fetch block info")
+		}
+	}
+	slot, err := m.checkpointSlot(ctx, holder, islot, block.Number())
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{fmt.Sprintf("%x", slot)}
+	return m.erc20.GetProof(ctx, keys, block)
+}
+
+// checkpointSlot resolves the storage slot of the checkpoint that was most
+// recently recorded at or before block, within the `Checkpoint[]` array
+// whose length lives at the slot returned by helpers.GetMapSlot(holder,
+// islot). A nil block skips the fromBlock scan and returns the very last
+// checkpoint, i.e. the holder's current balance. An empty array (length 0,
+// meaning the holder never held a balance) resolves to that length slot
+// itself, which then reads as zero - this is what GetZeroProof relies on.
+// If block predates the holder's very first checkpoint, it resolves to the
+// slot one past the end of the array: like the length-0 case, an index the
+// array has never written to, so it also reads back as zero.
+func (m *Minime) checkpointSlot(ctx context.Context, holder common.Address,
+	islot int, block *big.Int) (common.Hash, error) {
+	addr := common.Address{}
+	copy(addr[:], m.erc20.TokenAddr[:20])
+
+	lengthSlot, err := helpers.GetMapSlot(holder.Hex(), islot)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	lengthValue, err := m.erc20.Ethcli.StorageAt(ctx, addr, lengthSlot, nil)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("StorageAt: %w", err)
+	}
+	length := new(big.Int).SetBytes(lengthValue).Uint64()
+	if length == 0 {
+		return lengthSlot, nil
+	}
+
+	for i := length - 1; ; i-- {
+		elemSlot := checkpointElementSlot(lengthSlot, i)
+		if block == nil {
+			return elemSlot, nil
+		}
+		value, err := m.erc20.Ethcli.StorageAt(ctx, addr, elemSlot, nil)
+		if err != nil {
+			return common.Hash{}, fmt.Errorf("StorageAt: %w", err)
+		}
+		_, _, fromBlock, err := ParseMinimeValue(fmt.Sprintf("0x%x", value), 0)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		if fromBlock.Cmp(block) <= 0 {
+			return elemSlot, nil
+		}
+		if i == 0 {
+			return checkpointElementSlot(lengthSlot, length), nil
+		}
+	}
+}
+
+// checkpointElementSlot returns the storage slot of the index-th element of
+// the Checkpoint[] array whose length lives at lengthSlot, per Solidity's
+// dynamic array storage layout: elements start at keccak256(lengthSlot).
+func checkpointElementSlot(lengthSlot common.Hash, index uint64) common.Hash {
+	base := new(big.Int).SetBytes(crypto.Keccak256(lengthSlot.Bytes()))
+	return common.BigToHash(new(big.Int).Add(base, new(big.Int).SetUint64(index)))
+}
+
+// GetZeroProof returns a storage merkle proof attesting that holder had no
+// balance checkpoint at or before block, for the given storage slot: the
+// proven word is the length slot of an empty checkpoint array or, when
+// block predates the holder's first checkpoint, an array element slot
+// never written to. Pass islot=-1 to reuse the slot configured via
+// SetSlot. Returns ErrNotZero if the proven word is nonzero, which includes
+// a holder whose latest checkpoint records a zero balance.
+func (m *Minime) GetZeroProof(holder common.Address,
+	block *big.Int, islot int) (*ethstorageproof.StorageProof, error) {
+	if islot < 0 {
+		islot = m.islot
+	}
+	blockData, err := m.GetBlock(block)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	sproof, err := m.getCheckpointProof(ctx, holder, blockData, islot)
+	if err != nil {
+		return nil, err
+	}
+	if len(sproof.StorageProof) == 0 || sproof.StorageProof[0].Value == nil ||
+		sproof.StorageProof[0].Value.Sign() != 0 {
+		return nil, ErrNotZero
+	}
+	return sproof, nil
+}
+
+// GetProofBatch returns one storage merkle proof per holder, for the most
+// recent checkpoint of each, in two passes: the checkpoint element slot
+// depends on each holder's `Checkpoint[]` length, which a single
+// eth_getProof cannot know in advance, so the first pass fetches the
+// length word for every holder and the second pass fetches the actual
+// checkpoint element it resolves to. Both passes are fetched with as few
+// eth_getProof round-trips as possible: all storage keys for a chunk of
+// holders are requested in a single RPC call (the JSON-RPC eth_getProof
+// method accepts a `keys` array), and chunks are pipelined concurrently
+// across a worker pool once the holder set is larger than
+// maxKeysPerProofCall.
+func (m *Minime) GetProofBatch(holders []common.Address,
+	block *big.Int, slot int) ([]*ethstorageproof.StorageProof, error) {
+	blockData, err := m.GetBlock(block)
+	if err != nil {
+		return nil, err
+	}
+
+	lengthSlots := make([]common.Hash, len(holders))
+	lengthKeys := make([]string, len(holders))
+	for i, holder := range holders {
+		lengthSlot, err := helpers.GetMapSlot(holder.Hex(), slot)
+		if err != nil {
+			return nil, fmt.Errorf("GetMapSlot for %s: %w", holder.Hex(), err)
+		}
+		lengthSlots[i] = lengthSlot
+		lengthKeys[i] = fmt.Sprintf("%x", lengthSlot)
+	}
+	lengthProofs, err := m.fetchProofChunks(lengthKeys, blockData)
+	if err != nil {
+		return nil, fmt.Errorf("fetching checkpoint counts: %w", err)
+	}
+
+	keys := make([]string, len(holders))
+	for i, proof := range lengthProofs {
+		elemSlot := lengthSlots[i]
+		if len(proof.StorageProof) > 0 && proof.StorageProof[0].Value != nil {
+			if length := proof.StorageProof[0].Value.Uint64(); length > 0 {
+				elemSlot = checkpointElementSlot(lengthSlots[i], length-1)
+			}
+		}
+		keys[i] = fmt.Sprintf("%x", elemSlot)
+	}
+	return m.fetchProofChunks(keys, blockData)
+}
+
+// fetchProofChunks fetches one storage proof per key, with as few
+// eth_getProof round-trips as possible: keys are grouped into
+// maxKeysPerProofCall-sized chunks, each requested in a single RPC call,
+// and chunks are pipelined concurrently across a worker pool once there is
+// more than one of them.
+func (m *Minime) fetchProofChunks(keys []string, block *types.Block) ([]*ethstorageproof.StorageProof, error) {
+	numChunks := proofbatch.NumProofChunks(len(keys), maxKeysPerProofCall)
+	chunkProofs := make([]*ethstorageproof.StorageProof, numChunks)
+	chunkErrs := make([]error, numChunks)
+
+	jobs := make(chan int, numChunks)
+	for i := 0; i < numChunks; i++ {
+		jobs <- i
+	}
+	close(jobs)
+
+	workers := batchWorkers
+	if numChunks < workers {
+		workers = numChunks
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				start, end := proofbatch.ProofChunkBounds(i, len(keys), maxKeysPerProofCall)
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+				chunkProofs[i], chunkErrs[i] = m.erc20.GetProof(ctx, keys[start:end], block)
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	proofs := make([]*ethstorageproof.StorageProof, 0, len(keys))
+	for i, proof := range chunkProofs {
+		if chunkErrs[i] != nil {
+			return nil, fmt.Errorf("chunk %d: %w", i, chunkErrs[i])
+		}
+		start, end := proofbatch.ProofChunkBounds(i, len(keys), maxKeysPerProofCall)
+		if len(proof.StorageProof) != end-start {
+			return nil, fmt.Errorf("chunk %d: expected %d storage proofs, got %d",
+				i, end-start, len(proof.StorageProof))
+		}
+		for j := range proof.StorageProof {
+			proofs = append(proofs, &ethstorageproof.StorageProof{
+				Address:      proof.Address,
+				AccountProof: proof.AccountProof,
+				Balance:      proof.Balance,
+				CodeHash:     proof.CodeHash,
+				Nonce:        proof.Nonce,
+				StorageHash:  proof.StorageHash,
+				StorageProof: []ethstorageproof.StorageResult{proof.StorageProof[j]},
+			})
+		}
+	}
+	return proofs, nil
+}
+
+// DiscoverSlot tries to find the EVM storage index slot of the balances
+// checkpoint array. It first attempts static bytecode analysis (see
+// token/bytecode), which works without iterating DiscoveryIterations and
+// can find slots outside that range; if that fails, it falls back to
+// brute-force iteration comparing against a known holder balance. A token
+// holder address must be provided in order to have a balance to search and
+// compare. Returns ErrSlotNotFound if the slot cannot be found. If found,
+// returns also the amount stored.
+func (m *Minime) DiscoverSlot(holder common.Address) (int, *big.Float, error) {
+	tokenData, err := m.erc20.GetTokenData()
+	if err != nil {
+		return -1, nil, fmt.Errorf("GetTokenData: %w", err)
+	}
+	balance, err := m.erc20.Balance(holder)
+	if err != nil {
+		return -1, nil, fmt.Errorf("Balance: %w", err)
+	}
+	ubalance, _ := balance.Uint64()
+
+	addr := common.Address{}
+	copy(addr[:], m.erc20.TokenAddr[:20])
+	decimals := int(tokenData.Decimals)
+
+	if bslot, err := bytecode.DiscoverSlot(context.Background(), m.erc20.Ethcli, addr); err == nil {
+		if amount, ok, err := m.checkSlot(addr, holder, bslot, ubalance, decimals); err != nil {
+			return -1, nil, err
+		} else if ok {
+			return bslot, amount, nil
+		}
+	}
+
+	for i := 0; i < DiscoveryIterations; i++ {
+		amount, ok, err := m.checkSlot(addr, holder, i, ubalance, decimals)
+		if err != nil {
+			return -1, nil, err
+		}
+		if ok {
+			return i, amount, nil
+		}
+	}
+	return -1, nil, ErrSlotNotFound
+}
+
+// checkSlot reads the holder's latest checkpoint at islot and reports
+// whether its balance matches ubalance, returning the parsed amount when
+// it does. err is non-nil only when the lookup itself failed (e.g. an RPC
+// error); a nil error with ok=false means the slot was read successfully
+// but holds the wrong value.
+func (m *Minime) checkSlot(addr, holder common.Address,
+	islot int, ubalance uint64, decimals int) (amount *big.Float, ok bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	slot, err := m.checkpointSlot(ctx, holder, islot, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	value, err := m.erc20.Ethcli.StorageAt(ctx, addr, slot, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("StorageAt: %w", err)
+	}
+	amount, fullBalance, _, err := ParseMinimeValue(fmt.Sprintf("0x%x", value), decimals)
+	if err != nil {
+		return nil, false, err
+	}
+	if fullBalance.Uint64() != ubalance {
+		return nil, false, nil
+	}
+	return amount, true, nil
+}
+
+// ParseMinimeValue decodes a packed MiniMe checkpoint storage word into its
+// token balance, the raw (undecimalled) balance, and the block at which the
+// checkpoint was recorded. A checkpoint packs `value` in the upper 128 bits
+// and `fromBlock` in the lower 128 bits of the 256 bit storage word.
+func ParseMinimeValue(value string, decimals int) (*big.Float, *big.Int, *big.Int, error) {
+	// Accept "0x"-prefixed hex and decimal strings (raw storage words are
+	// passed "0x"-prefixed, big.Int values as decimal), falling back to
+	// plain hex without a prefix. A prefix-less string made only of digits
+	// is read as decimal (or octal with a leading zero), not as hex.
+	raw, ok := new(big.Int).SetString(value, 0)
+	if !ok {
+		raw, ok = new(big.Int).SetString(value, 16)
+	}
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("cannot parse checkpoint value %q", value)
+	}
+	mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+	fromBlock := new(big.Int).And(raw, mask)
+	fullBalance := new(big.Int).Rsh(raw, 128)
+
+	balance, err := helpers.ValueToBalance(fmt.Sprintf("%x", fullBalance), decimals)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return balance, fullBalance, fromBlock, nil
+}
+
+// VerifyProof checks that the given storage proof attests that holder held
+// fullBalance at block, by recomputing the checkpoint it encodes and
+// comparing it against the claimed values. It does not check that the
+// proof's storage key belongs to holder and slot: holder, storageHash and
+// slot are not used. Callers should additionally run
+// ethstorageproof.VerifyEIP1186 on the full proof bundle to verify the
+// merkle-trie inclusion of that storage value.
+func VerifyProof(holder common.Address, storageHash common.Hash,
+	storageProof []ethstorageproof.StorageResult, slot int, fullBalance *big.Int, block *big.Int) error {
+	if len(storageProof) == 0 {
+		return fmt.Errorf("%w: empty storage proof", ErrCheckpointMismatch)
+	}
+	value := storageProof[0].Value
+	if value == nil {
+		if fullBalance.Sign() == 0 {
+			return nil
+		}
+		return fmt.Errorf("%w: got balance 0, expected %s", ErrCheckpointMismatch, fullBalance)
+	}
+	_, gotBalance, gotBlock, err := ParseMinimeValue(value.String(), 0)
+	if err != nil {
+		return err
+	}
+	if gotBalance.Cmp(fullBalance) != 0 {
+		return fmt.Errorf("%w: got balance %s, expected %s", ErrCheckpointMismatch, gotBalance, fullBalance)
+	}
+	if block != nil && gotBlock.Cmp(block) > 0 {
+		return fmt.Errorf("%w: checkpoint from block %s is newer than requested block %s",
+			ErrCheckpointMismatch, gotBlock, block)
+	}
+	return nil
+}
+
+// proofAt is the shared implementation behind ProofAt and BalanceAt. It
+// additionally returns the Minime instance that actually served the proof,
+// so BalanceAt can fetch token metadata (decimals) from the endpoint that
+// worked rather than always redialing the original one.
+func (m *Minime) proofAt(holder common.Address, block *big.Int, slot int) (*ethstorageproof.StorageProof, *Minime, error) {
+	endpoints := append([]string{m.web3endpoint}, m.fallbackEndpoints...)
+	sproof, prover, err := proofbatch.ProofAt(m, func() proofbatch.Prover { return &Minime{} },
+		m.tokenAddress, endpoints, holder, block, slot)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sproof, prover.(*Minime), nil
+}
+
+// ProofAt returns a storage merkle proof for holder's most recent
+// checkpoint at block and slot, probing the primary endpoint first (reusing
+// m's existing connection) and then, if it turns out not to be an archive
+// node for that block, each endpoint configured via SetFallbackEndpoints in
+// order. Returns a *proofbatch.EndpointsExhaustedError if none of them can
+// serve the proof.
+func (m *Minime) ProofAt(holder common.Address, block *big.Int, slot int) (*ethstorageproof.StorageProof, error) {
+	sproof, _, err := m.proofAt(holder, block, slot)
+	return sproof, err
+}
+
+// BalanceAt returns holder's token balance at block, using the same
+// archive-node fallback behaviour as ProofAt.
+func (m *Minime) BalanceAt(holder common.Address, block *big.Int, slot int) (*big.Float, error) {
+	sproof, mm, err := m.proofAt(holder, block, slot)
+	if err != nil {
+		return nil, err
+	}
+	if len(sproof.StorageProof) == 0 || sproof.StorageProof[0].Value == nil {
+		return big.NewFloat(0), nil
+	}
+	tokenData, err := mm.erc20.GetTokenData()
+	if err != nil {
+		return nil, fmt.Errorf("GetTokenData: %w", err)
+	}
+	balance, _, _, err := ParseMinimeValue(sproof.StorageProof[0].Value.String(), int(tokenData.Decimals))
+	return balance, err
+}
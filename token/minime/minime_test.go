@@ -0,0 +1,103 @@
+package minime
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/storage-proofs-eth-go/ethstorageproof"
+)
+
+func TestVerifyProofMatch(t *testing.T) {
+	holder := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	// value=1000, fromBlock=42, packed as value<<128 | fromBlock.
+	raw, _ := new(big.Int).SetString("3e80000000000000000000000000000002a", 16)
+	storageProof := []ethstorageproof.StorageResult{{Value: raw}}
+
+	err := VerifyProof(holder, common.Hash{}, storageProof, 0, big.NewInt(1000), big.NewInt(100))
+	if err != nil {
+		t.Fatalf("VerifyProof: %v", err)
+	}
+}
+
+func TestVerifyProofBalanceMismatch(t *testing.T) {
+	holder := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	raw, _ := new(big.Int).SetString("3e80000000000000000000000000000002a", 16)
+	storageProof := []ethstorageproof.StorageResult{{Value: raw}}
+
+	err := VerifyProof(holder, common.Hash{}, storageProof, 0, big.NewInt(999), big.NewInt(100))
+	if !errors.Is(err, ErrCheckpointMismatch) {
+		t.Fatalf("VerifyProof error = %v, want ErrCheckpointMismatch", err)
+	}
+}
+
+func TestVerifyProofCheckpointTooNew(t *testing.T) {
+	holder := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	// value=1000, fromBlock=42.
+	raw, _ := new(big.Int).SetString("3e80000000000000000000000000000002a", 16)
+	storageProof := []ethstorageproof.StorageResult{{Value: raw}}
+
+	err := VerifyProof(holder, common.Hash{}, storageProof, 0, big.NewInt(1000), big.NewInt(10))
+	if !errors.Is(err, ErrCheckpointMismatch) {
+		t.Fatalf("VerifyProof error = %v, want ErrCheckpointMismatch", err)
+	}
+}
+
+func TestVerifyProofEmptyStorageProof(t *testing.T) {
+	holder := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	err := VerifyProof(holder, common.Hash{}, nil, 0, big.NewInt(1000), big.NewInt(100))
+	if !errors.Is(err, ErrCheckpointMismatch) {
+		t.Fatalf("VerifyProof error = %v, want ErrCheckpointMismatch", err)
+	}
+}
+
+func TestVerifyProofNilValueZeroBalance(t *testing.T) {
+	holder := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	storageProof := []ethstorageproof.StorageResult{{Value: nil}}
+
+	err := VerifyProof(holder, common.Hash{}, storageProof, 0, big.NewInt(0), big.NewInt(100))
+	if err != nil {
+		t.Fatalf("VerifyProof: %v", err)
+	}
+}
+
+func TestVerifyProofNilValueNonzeroBalance(t *testing.T) {
+	holder := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	storageProof := []ethstorageproof.StorageResult{{Value: nil}}
+
+	err := VerifyProof(holder, common.Hash{}, storageProof, 0, big.NewInt(1000), big.NewInt(100))
+	if !errors.Is(err, ErrCheckpointMismatch) {
+		t.Fatalf("VerifyProof error = %v, want ErrCheckpointMismatch", err)
+	}
+}
+
+func TestParseMinimeValue(t *testing.T) {
+	// value=1000, fromBlock=42, packed as value<<128 | fromBlock.
+	raw := "3e80000000000000000000000000000002a"
+	balance, fullBalance, fromBlock, err := ParseMinimeValue(raw, 0)
+	if err != nil {
+		t.Fatalf("ParseMinimeValue: %v", err)
+	}
+	if fullBalance.Int64() != 1000 {
+		t.Errorf("fullBalance = %s, want 1000", fullBalance)
+	}
+	if fromBlock.Int64() != 42 {
+		t.Errorf("fromBlock = %s, want 42", fromBlock)
+	}
+	if f, _ := balance.Float64(); f != 1000 {
+		t.Errorf("balance = %v, want 1000", f)
+	}
+}
+
+func TestCheckpointElementSlot(t *testing.T) {
+	lengthSlot := common.HexToHash("0x0f")
+	first := checkpointElementSlot(lengthSlot, 0)
+	second := checkpointElementSlot(lengthSlot, 1)
+	if first == second {
+		t.Errorf("checkpointElementSlot(lengthSlot, 0) == checkpointElementSlot(lengthSlot, 1): %s", first.Hex())
+	}
+	if got := new(big.Int).Sub(second.Big(), first.Big()); got.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("checkpointElementSlot index 1 - index 0 = %s, want 1", got)
+	}
+}
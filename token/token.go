@@ -0,0 +1,61 @@
+// Package token provides a common abstraction over the different storage
+// layouts used by ERC20-like tokens to keep balances, so that the rest of
+// the pipeline (discovery, proof fetching, verification) can be driven
+// without caring which layout a given contract uses.
+package token
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/vocdoni/storage-proofs-eth-go/ethstorageproof"
+	"github.com/vocdoni/storage-proofs-eth-go/token/mapbased"
+	"github.com/vocdoni/storage-proofs-eth-go/token/minime"
+)
+
+// Token types currently supported by NewToken. ERC721 and ERC1155 tokens
+// are keyed by tokenId in addition to holder address, so they do not fit
+// this interface and are served by the dedicated token/erc721 and
+// token/erc1155 packages instead.
+const (
+	TokenTypeUnknown = iota
+	TokenTypeMapbased
+	TokenTypeMinime
+	TokenTypeERC721
+	TokenTypeERC1155
+)
+
+// ErrTokenTypeNotSupported is returned by NewToken for token types that
+// cannot be represented through the single holder-balance interface.
+var ErrTokenTypeNotSupported = fmt.Errorf("token type not supported by this interface")
+
+// Token is implemented by every token storage layout that keeps a single
+// balance per holder address (mapbased and minime). NFT layouts (ERC721,
+// ERC1155) are additionally keyed by tokenId and live in their own packages.
+type Token interface {
+	Init(tokenAddress, web3endpoint string) error
+	GetBlock(block *big.Int) (*types.Block, error)
+	GetProof(holder common.Address, block *big.Int, islot int) (*ethstorageproof.StorageProof, error)
+	DiscoverSlot(holder common.Address) (int, *big.Float, error)
+}
+
+// NewToken builds and initializes a Token implementation for ttype.
+func NewToken(ttype int, tokenAddress, web3endpoint string) (Token, error) {
+	var t Token
+	switch ttype {
+	case TokenTypeMapbased:
+		t = &mapbased.Mapbased{}
+	case TokenTypeMinime:
+		t = &minime.Minime{}
+	case TokenTypeERC721, TokenTypeERC1155:
+		return nil, fmt.Errorf("%w: use the token/erc721 or token/erc1155 package directly", ErrTokenTypeNotSupported)
+	default:
+		return nil, fmt.Errorf("token type not supported")
+	}
+	if err := t.Init(tokenAddress, web3endpoint); err != nil {
+		return nil, err
+	}
+	return t, nil
+}